@@ -0,0 +1,310 @@
+package color
+
+import (
+  "fmt"
+  "strconv"
+  "strings"
+  "unicode/utf8"
+)
+
+//===========================================
+//  ANSI STREAM PARSING
+//===========================================
+
+//ANSIParser turns raw terminal output into spans. SGR sequences become style tags
+//("fg=red", "fg=#aabbcc", "bold=reset"), OSC 8 hyperlinks become "link=<url>" and
+//"link=reset" tags, every other escape sequence is dropped.
+//sequences split across Feed calls are kept until the rest arrives, up to
+//maxPendingSequence bytes: past that the sequence is taken as text
+type ANSIParser struct {
+  //called with every escape sequence (or SGR code) that has no tag, in input order.
+  //when nil those are dropped
+  Unsupported func(seq string)
+  pending     []byte
+}
+
+//maxPendingSequence is the longest unterminated CSI or OSC kept waiting for its end
+const maxPendingSequence = 4096
+
+func (p *ANSIParser) unsupported(seq string) {
+  if p.Unsupported != nil {
+    p.Unsupported(seq)
+  }
+}
+
+//Feed parses the next chunk of input and passes every span to emit
+func (p *ANSIParser) Feed(data []byte, emit func(Span)) {
+  buf := append(p.pending, data...)
+  p.pending = nil
+
+  text := 0
+  i := 0
+  for i < len(buf) {
+    if buf[i] != 0x1b {
+      i++
+      continue
+    }
+    if text < i {
+      emit(Span{Text: string(buf[text:i])})
+    }
+    n, complete := p.sequence(buf[i:], emit)
+    if !complete && len(buf)-i > maxPendingSequence {
+      //too long to be a sequence: drop the introducer, the rest is text
+      i += introducerLength(buf[i:])
+      text = i
+      continue
+    }
+    if !complete {
+      p.pending = append([]byte(nil), buf[i:]...)
+      return
+    }
+    i += n
+    text = i
+  }
+
+  //keep a rune that was cut in half for the next chunk
+  end := len(buf)
+  for back := 1; back <= utf8.UTFMax && back <= end-text; back++ {
+    if utf8.RuneStart(buf[end-back]) {
+      if !utf8.FullRune(buf[end-back:]) {
+        p.pending = append([]byte(nil), buf[end-back:]...)
+        end -= back
+      }
+      break
+    }
+  }
+  if text < end {
+    emit(Span{Text: string(buf[text:end])})
+  }
+}
+
+//Flush ends the input. a sequence still waiting for the rest loses its
+//introducer and what follows is parsed as text, so nothing after an
+//unterminated escape is lost
+func (p *ANSIParser) Flush(emit func(Span)) {
+  for len(p.pending) > 0 {
+    rest := p.pending
+    p.pending = nil
+    if rest[0] != 0x1b {
+      emit(Span{Text: string(rest)})
+      return
+    }
+    p.Feed(rest[introducerLength(rest):], emit)
+  }
+}
+
+//introducerLength is how many bytes open the escape sequence at seq[0] == ESC:
+//ESC and the byte after it, or ESC alone at the end of the input
+func introducerLength(seq []byte) int {
+  return min(2, len(seq))
+}
+
+//sequence reads one escape sequence starting at seq[0] == ESC.
+//it returns how many bytes it used, or false when the sequence isn't complete yet
+func (p *ANSIParser) sequence(seq []byte, emit func(Span)) (int, bool) {
+  if len(seq) < 2 {
+    return 0, false
+  }
+
+  switch seq[1] {
+  case '[':
+    //CSI: parameters, intermediates then a final byte
+    for i := 2; i < len(seq); i++ {
+      if seq[i] >= 0x40 && seq[i] <= 0x7e {
+        if seq[i] == 'm' {
+          tags, unknown := sgrTags(string(seq[2:i]))
+          for _, tag := range tags {
+            emit(Span{Tag: tag})
+          }
+          if len(unknown) > 0 {
+            p.unsupported("\033[" + strings.Join(unknown, ";") + "m")
+          }
+        } else {
+          p.unsupported(string(seq[:i+1]))
+        }
+        return i + 1, true
+      }
+    }
+    return 0, false
+  case ']':
+    //OSC: ends with BEL or ESC \
+    for i := 2; i < len(seq); i++ {
+      if seq[i] == 0x07 {
+        p.osc(string(seq[2:i]), string(seq[:i+1]), emit)
+        return i + 1, true
+      }
+      if seq[i] == 0x1b {
+        if i+1 >= len(seq) {
+          return 0, false
+        }
+        if seq[i+1] == '\\' {
+          p.osc(string(seq[2:i]), string(seq[:i+2]), emit)
+          return i + 2, true
+        }
+      }
+    }
+    return 0, false
+  }
+  //ESC, intermediates (ESC ( B) then a final byte, or a two byte escape
+  i := 1
+  for i < len(seq) && seq[i] >= 0x20 && seq[i] <= 0x2f {
+    i++
+  }
+  if i >= len(seq) {
+    return 0, false
+  }
+  p.unsupported(string(seq[:i+1]))
+  return i + 1, true
+}
+
+func (p *ANSIParser) osc(body, raw string, emit func(Span)) {
+  //OSC 8 ; params ; uri
+  if !strings.HasPrefix(body, "8;") {
+    p.unsupported(raw)
+    return
+  }
+  fields := strings.SplitN(body, ";", 3)
+  if len(fields) < 3 || fields[2] == "" {
+    emit(Span{Tag: "link=reset"})
+    return
+  }
+  emit(Span{Tag: "link=" + fields[2]})
+}
+
+
+var sgrStyleTags = map[int]string{
+  0: "reset",
+  1: "bold",
+  2: "dim",
+  3: "italic",
+  4: "underline=single",
+  5: "blink=slow",
+  6: "blink=fast",
+  7: "reverse",
+  8: "hidden",
+  9: "strike",
+  21: "underline=double",
+  22: "bold=reset",
+  23: "italic=reset",
+  24: "underline=reset",
+  25: "blink=reset",
+  27: "reverse=reset",
+  28: "hidden=reset",
+  29: "strike=reset",
+  39: "fg=reset",
+  49: "bg=reset",
+}
+
+//sgrTags converts the parameters of one SGR sequence into style tags.
+//codes without a tag are returned in unknown
+func sgrTags(params string) (tags []string, unknown []string) {
+  if params == "" {
+    return []string{"reset"}, nil
+  }
+
+  fields := strings.Split(params, ";")
+  for i := 0; i < len(fields); i++ {
+    //colon form keeps the sub parameters in one field (38:2::R:G:B)
+    sub := strings.Split(fields[i], ":")
+    code, err := strconv.Atoi(sub[0])
+    if err != nil {
+      if sub[0] == "" {
+        code = 0
+      } else {
+        unknown = append(unknown, fields[i])
+        continue
+      }
+    }
+
+    switch {
+    case code == 38 || code == 48 || code == 58:
+      colon := len(sub) > 1
+      args := fields[i+1:]
+      if colon {
+        args = sub[1:]
+      }
+      tag, used := extendedColorTag(code, args, colon)
+      if tag != "" {
+        tags = append(tags, tag)
+      } else if colon {
+        unknown = append(unknown, fields[i])
+      } else {
+        unknown = append(unknown, fields[i:i+1+min(used, len(args))]...)
+      }
+      if !colon {
+        i += used
+      }
+    case code == 4 && len(sub) > 1:
+      //4:0 off, 4:1 single, 4:2 double, 4:3 curly...
+      switch sub[1] {
+      case "0":
+        tags = append(tags, "underline=reset")
+      case "2":
+        tags = append(tags, "underline=double")
+      default:
+        tags = append(tags, "underline=single")
+      }
+    case code >= 30 && code <= 37:
+      tags = append(tags, "fg="+namedColors[code-30])
+    case code >= 40 && code <= 47:
+      tags = append(tags, "bg="+namedColors[code-40])
+    case code >= 90 && code <= 97:
+      tags = append(tags, "fg="+namedColors[code-90+8])
+    case code >= 100 && code <= 107:
+      tags = append(tags, "bg="+namedColors[code-100+8])
+    default:
+      if tag, exists := sgrStyleTags[code]; exists {
+        tags = append(tags, tag)
+      } else {
+        unknown = append(unknown, fields[i])
+      }
+    }
+  }
+  return tags, unknown
+}
+
+//extendedColorTag reads the arguments after 38/48 (5;N or 2;R;G;B).
+//it returns the tag and how many arguments it used. 58 (underline color) is read but has no tag
+func extendedColorTag(code int, args []string, colon bool) (string, int) {
+  prefix := "fg="
+  if code == 48 {
+    prefix = "bg="
+  }
+  if len(args) == 0 {
+    return "", 0
+  }
+
+  switch args[0] {
+  case "5":
+    if len(args) < 2 {
+      return "", len(args)
+    }
+    index, err := strconv.Atoi(args[1])
+    if err != nil || index < 0 || index > 255 || code == 58 {
+      return "", 2
+    }
+    return prefix + strconv.Itoa(index), 2
+  case "2":
+    if len(args) < 4 {
+      return "", len(args)
+    }
+    rgb := args[1:4]
+    //the colon form may carry a color space id before r:g:b
+    if colon && len(args) > 4 {
+      rgb = args[len(args)-3:]
+    }
+    var values [3]int
+    for j, field := range rgb {
+      value, err := strconv.Atoi(field)
+      if err != nil || value < 0 || value > 255 {
+        return "", 4
+      }
+      values[j] = value
+    }
+    if code == 58 {
+      return "", 4
+    }
+    return fmt.Sprintf("%s#%02x%02x%02x", prefix, values[0], values[1], values[2]), 4
+  }
+  return "", 1
+}
@@ -0,0 +1,81 @@
+package color
+
+import (
+  "os"
+  "strconv"
+  "strings"
+)
+
+//===========================================
+//  BACKGROUND AND ALPHA
+//===========================================
+
+//detectBackground reads COLORFGBG ("15;0" is light text on black), which many
+//terminals set. without it the background is taken to be black
+func detectBackground() Color {
+  fields := strings.Split(os.Getenv("COLORFGBG"), ";")
+  index, err := strconv.Atoi(fields[len(fields)-1])
+  if err != nil || index < 0 || index > 255 {
+    return XtermPalette16[0]
+  }
+  return Ansi256(uint8(index))
+}
+
+//colorContext is what the parser knows about the colors in effect at one point of a template
+type colorContext struct {
+  bg Color
+  //the tag in effect for each color target and style, keyed "fg", "bold", "underline"...
+  active map[string]string
+}
+
+func (toggle *ColorToggle) newColorContext() colorContext {
+  return colorContext{bg: toggle.Background, active: map[string]string{}}
+}
+
+//track records what a resolved tag turns on or off
+func (ctx *colorContext) track(tag string) {
+  key, value, _ := strings.Cut(tag, "=")
+  switch {
+  case tag == "reset":
+    clear(ctx.active)
+  case value == "reset":
+    delete(ctx.active, key)
+  default:
+    ctx.active[key] = tag
+  }
+}
+
+//tagPart turns one supported word of a tag into a part. colors with alpha are
+//composited over the bg= in effect, or the toggle background when there is none,
+//and are kept in the part as the solid color they became
+func (toggle *ColorToggle) tagPart(word string, ctx *colorContext) TempPart {
+  spec, err := ParseColorSpec(word)
+  if err != nil {
+    return TempPart{Index: -1, Tag: word}
+  }
+
+  tag := word
+  if spec.Kind == KindAuto {
+    spec.Color, spec.Kind = readableOn(ctx.bg), KindHex
+    tag = spec.Color.Fg()
+  }
+  if spec.Target != TargetNone && spec.Kind != KindReset {
+    if spec.Alpha < 1 {
+      spec.Color = spec.Color.Blend(ctx.bg, spec.Alpha)
+      spec.Kind, spec.Alpha = KindHex, 1
+      tag = strings.SplitN(word, "=", 2)[0] + "=" + spec.Color.String()
+    }
+    if remapped := toggle.CVDMode.remap(spec, toggle.Level); remapped != spec {
+      spec = remapped
+      tag = spec.String()
+    }
+    if spec.Target == TargetBg {
+      ctx.bg = spec.Color
+    }
+  } else if word == "reset" || word == "bg=reset" {
+    ctx.bg = toggle.Background
+  }
+
+  ctx.track(tag)
+  return TempPart{Text: spec.sgr(toggle.Level, toggle.Palette), Index: -1, Tag: tag}
+}
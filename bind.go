@@ -0,0 +1,135 @@
+package color
+
+//===========================================
+//  PARTIAL APPLICATION
+//===========================================
+
+/* Bind fills some placeholders once and returns a template with the rest:
+
+     prefix := color.Parse("[0 fg=hash] [1|upper] [2]").Bind(0, "api")
+     prefix.Apply("info", "listening")   //the remaining slots are renumbered to 0 and 1
+
+   bound values are rendered into text parts, with their filters and styles, so
+   the new template has nothing left to do for them at Apply time */
+
+type bindConfig struct {
+  keepNumbers bool
+}
+
+//BindOption changes how Bind and BindMap number the placeholders left
+type BindOption func(*bindConfig)
+
+//KeepNumbers leaves the remaining placeholders at their numbers, so
+//Parse("[0] [1]").Bind(0, x) still takes its value as the second argument
+func KeepNumbers() BindOption {
+  return func(config *bindConfig) {
+    config.keepNumbers = true
+  }
+}
+
+//Bind fills placeholder index with value
+func (temp CompiledTemplate) Bind(index int, value any, opts ...BindOption) CompiledTemplate {
+  return temp.BindMap(map[int]any{index: value}, opts...)
+}
+
+//BindMap fills the placeholders of values at once. without KeepNumbers the ones
+//left are renumbered from 0 in their order
+func (temp CompiledTemplate) BindMap(values map[int]any, opts ...BindOption) CompiledTemplate {
+  var config bindConfig
+  for _, opt := range opts{
+    opt(&config)
+  }
+
+  //the bound values as an argument list, for rendering the parts that use them
+  last := -1
+  for index := range values{
+    last = max(last, index)
+  }
+  args := make([]any, last+1)
+  for index, value := range values{
+    if index >= 0 {
+      args[index] = value
+    }
+  }
+
+  renumber := func(index int) int {
+    if config.keepNumbers {
+      return index
+    }
+    shifted := index
+    for bound := range values{
+      if bound >= 0 && bound < index {
+        shifted--
+      }
+    }
+    return shifted
+  }
+  return temp.bind(values, args, renumber)
+}
+
+func (temp CompiledTemplate) bind(values map[int]any, args []any, renumber func(int) int) CompiledTemplate {
+  bound := CompiledTemplate{Parts: make([]TempPart, 0, len(temp.Parts)), toggle: temp.toggle}
+  for _, part := range temp.Parts{
+    switch {
+    case part.block != nil:
+      if allBound(part.block.inner, values) {
+        bound.Parts = append(bound.Parts, part.block.boundParts(args)...)
+        continue
+      }
+      block := *part.block
+      block.inner = block.inner.bind(values, args, renumber)
+      part.block = &block
+      bound.Parts = append(bound.Parts, part)
+
+    case part.Index >= 0:
+      if _, isBound := values[part.Index]; isBound {
+        if text, ok := part.value(args); ok {
+          bound.Parts = append(bound.Parts, TempPart{Text: text, Index: -1})
+        }
+        continue
+      }
+      part.Index = renumber(part.Index)
+      bound.Parts = append(bound.Parts, part)
+
+    default:
+      bound.Parts = append(bound.Parts, part)
+    }
+  }
+  for _, part := range bound.Parts{
+    bound.TotalLength += len(part.Text)
+  }
+  return bound
+}
+
+//allBound reports if every placeholder of temp has a value
+func allBound(temp CompiledTemplate, values map[int]any) bool {
+  for _, slot := range temp.Slots(){
+    if _, isBound := values[slot]; !isBound {
+      return false
+    }
+  }
+  return true
+}
+
+//boundParts renders the block once and keeps the result as parts: the tags stay
+//for renderers, with the escape codes apply would write for them
+func (block *tagBlock) boundParts(args []any) []TempPart {
+  var (
+    parts    []TempPart
+    lastCode = ""
+  )
+  for _, span := range block.spans(args){
+    if span.Tag == ""{
+      parts = append(parts, TempPart{Text: span.Text, Index: -1})
+      continue
+    }
+    code := tagCode(span.Tag, block.level, block.palette)
+    if code == lastCode {
+      code = ""
+    } else {
+      lastCode = code
+    }
+    parts = append(parts, TempPart{Text: code, Index: -1, Tag: span.Tag})
+  }
+  return parts
+}
@@ -0,0 +1,405 @@
+package color
+
+import (
+  "fmt"
+  "maps"
+  "math"
+  "os"
+  "slices"
+  "strconv"
+  "strings"
+  "sync"
+  "unicode"
+)
+
+//===========================================
+//  BLOCK TAGS
+//===========================================
+
+//a block tag works on the text between [name] or [name=arg] and [/name].
+//the inner text is parsed like any template, placeholders included, and the
+//block is rendered at Apply time
+
+//TagHandler renders a block tag: it gets the arg after '=' ("" without one) and the
+//inner text as spans, with its placeholders filled, and returns the spans to write
+type TagHandler func(arg string, inner []Span) []Span
+
+type blockTag struct {
+  //checks the arg at parse time, a tag with a bad arg is left as text
+  validate func(arg string) error
+  render   TagHandler
+  //the tag sets the fg of its text itself, the fg in effect where the
+  //block opens is put back after it
+  setsFg bool
+}
+
+var (
+  blockTagsMu sync.RWMutex
+  blockTags   = map[string]blockTag{
+    "gradient":  {validate: validateStops, render: gradientBlock, setsFg: true},
+    "vgradient": {validate: validateStops, render: verticalGradientBlock, setsFg: true},
+    "rainbow":   {validate: noArg, render: rainbowBlock, setsFg: true},
+    "upper":     {validate: noArg, render: textBlock(strings.ToUpper)},
+    "lower":     {validate: noArg, render: textBlock(strings.ToLower)},
+    "pad":       {validate: widthArg, render: padBlock},
+    "lpad":      {validate: widthArg, render: leftPadBlock},
+    "center":    {validate: optionalWidthArg, render: centerBlock},
+  }
+)
+
+//RegisterTag adds a block tag for templates parsed after it, or replaces the one with
+//that name. [name]...[/name] and [name=arg]...[/name] are only blocks for registered
+//names. a name can't be a word templates already use (see reservedTagName), or
+//hold spaces, '=', '|', '/' or brackets
+func RegisterTag(name string, handler TagHandler) {
+  if name == "" || reservedTagName(name) || strings.ContainsAny(name, "=[]/|") ||
+    strings.ContainsFunc(name, unicode.IsSpace) || handler == nil {
+    panic(fmt.Sprintf("color: RegisterTag %q: bad name or nil handler", name))
+  }
+  blockTagsMu.Lock()
+  defer blockTagsMu.Unlock()
+  blockTags[name] = blockTag{render: handler}
+}
+
+//reservedWords are tag and placeholder words that aren't in the style, reset or color maps
+var reservedWords = map[string]bool{
+  "raw": true, "reset": true, "link": true,
+  "scale": true, "heat": true, "map": true, "hash": true, "auto": true,
+}
+
+//reservedTagName reports if name already means something inside []: a style,
+//the target or style of a reset, a color name or a placeholder word
+func reservedTagName(name string) bool {
+  _, isStyle := StyleMap[name]
+  _, isTarget := targetPrefixes[name]
+  _, hasReset := ResetMap[name+"=reset"]
+  _, isCSS := CSSColors[name]
+  return reservedWords[name] || isStyle || isTarget || hasReset || isCSS || slices.Contains(namedColors, name)
+}
+
+func lookupBlockTag(name string) (blockTag, bool) {
+  blockTagsMu.RLock()
+  defer blockTagsMu.RUnlock()
+  tag, exists := blockTags[name]
+  return tag, exists
+}
+
+//tagBlock is a parsed block inside a template
+type tagBlock struct {
+  name    string
+  arg     string
+  tag     blockTag
+  inner   CompiledTemplate
+  level   ColorLevel
+  //the colors in effect where the block opens
+  outside colorContext
+  //the tags a styled placeholder always sets on its value, for the contrast check
+  fixed []string
+  //the toggle's 16 color palette, nil for XtermPalette16
+  palette *Palette16
+  cvd     CVD
+}
+
+//spans renders the inner template and hands it to the block
+func (block *tagBlock) spans(args []any) []Span {
+  spans := block.tag.render(block.arg, block.inner.Spans(args...))
+  if block.cvd != CVDNone {
+    for i := range spans{
+      if spans[i].Tag != ""{
+        spans[i].Tag = block.cvd.remapTag(spans[i].Tag, block.level)
+      }
+    }
+  }
+  //the outside tags were remapped when they were parsed
+  if block.tag.setsFg {
+    spans = append(spans, Span{Tag: restoreWord("fg=", block.outside)})
+  }
+  return spans
+}
+
+//apply renders the block as escape sequences for the level it was parsed with
+func (block *tagBlock) apply(args []any) string {
+  var (
+    result   strings.Builder
+    lastCode = ""
+  )
+  for _, span := range block.spans(args){
+    if span.Tag == ""{
+      result.WriteString(span.Text)
+      continue
+    }
+    //neighbouring colors often come out the same once brought down to 256 or 16
+    if code := tagCode(span.Tag, block.level, block.palette); code != lastCode {
+      result.WriteString(code)
+      lastCode = code
+    }
+  }
+  return result.String()
+}
+
+
+//parseBlock checks if the tag at input[start:] opens a block with a matching close.
+//it returns the block part and where the text after the close starts
+func (toggle *ColorToggle) parseBlock(input string, start int, content string, ctx colorContext) (TempPart, int, bool) {
+  words := tagWords(content)
+  if len(words) != 1 {
+    return TempPart{}, 0, false
+  }
+  name, arg, _ := strings.Cut(words[0], "=")
+  tag, exists := lookupBlockTag(name)
+  if !exists {
+    return TempPart{}, 0, false
+  }
+  if tag.validate != nil && tag.validate(arg) != nil {
+    return TempPart{}, 0, false
+  }
+
+  innerStart := start + len(content) + 2
+  innerEnd, after := findBlockClose(input, innerStart, name)
+  if innerEnd < 0 {
+    return TempPart{}, 0, false
+  }
+
+  //the inner colors are remapped with the block's own, after it renders, and
+  //their contrast is checked with the template's
+  plain := *toggle
+  plain.CVDMode = CVDNone
+  plain.MinContrast = 0
+  inside := colorContext{bg: ctx.bg, active: maps.Clone(ctx.active)}
+  block := &tagBlock{
+    name:  name,
+    arg:   arg,
+    tag:   tag,
+    inner: plain.parse(input[innerStart:innerEnd], inside),
+    level:   toggle.Level,
+    outside: colorContext{bg: ctx.bg, active: maps.Clone(ctx.active)},
+    palette: toggle.Palette,
+    cvd:     toggle.CVDMode,
+  }
+  return TempPart{Index: -1, block: block}, after, true
+}
+
+//findBlockClose finds the [/name] that closes a block, skipping nested blocks of the same name
+func findBlockClose(input string, from int, name string) (int, int) {
+  closing := "[/" + name + "]"
+  depth := 0
+  for i := from; i < len(input); i++ {
+    rest := input[i:]
+    switch {
+    case strings.HasPrefix(rest, closing):
+      if depth == 0 {
+        return i, i + len(closing)
+      }
+      depth--
+    case strings.HasPrefix(rest, "["+name+"]") || strings.HasPrefix(rest, "["+name+"="):
+      depth++
+    }
+  }
+  return -1, -1
+}
+
+
+//======================================
+// GRADIENTS
+//======================================
+
+func noArg(arg string) error {
+  if arg != ""{
+    return fmt.Errorf("takes no value")
+  }
+  return nil
+}
+
+//splitStops splits "#ff0000:css:red:blue" into its colors.
+//css: names carry a colon of their own
+func splitStops(arg string) []string {
+  fields := strings.Split(arg, ":")
+  var values []string
+  for i := 0; i < len(fields); i++ {
+    value := fields[i]
+    if value == "css" && i+1 < len(fields) {
+      i++
+      value += ":" + fields[i]
+    }
+    values = append(values, value)
+  }
+  return values
+}
+
+//gradientStops reads "#ff0000:#0000ff" or any number of colors split by ':'
+func gradientStops(arg string) ([]Color, error) {
+  var stops []Color
+  for _, value := range splitStops(arg){
+    spec, err := ParseColorSpec("fg=" + value)
+    if err != nil || spec.Kind == KindReset {
+      return nil, fmt.Errorf("color: gradient stop %q is not a color", value)
+    }
+    stops = append(stops, spec.Color)
+  }
+  if len(stops) < 2 {
+    return nil, fmt.Errorf("color: a gradient needs at least 2 colors")
+  }
+  return stops, nil
+}
+
+func validateStops(arg string) error {
+  _, err := gradientStops(arg)
+  return err
+}
+
+//gradientAt returns the color at t (0-1) along the stops, mixed in OKLab
+func gradientAt(stops []Color, t float64) Color {
+  //NaN would index out of range
+  if t <= 0 || math.IsNaN(t) {
+    return stops[0]
+  }
+  if t >= 1 {
+    return stops[len(stops)-1]
+  }
+  position := t * float64(len(stops)-1)
+  segment := int(position)
+  return stops[segment].Mix(stops[segment+1], position-float64(segment))
+}
+
+//colorGraphemes gives every visible grapheme of the text spans the color colorAt returns.
+//colorAt gets the grapheme's number, the total and the line it is on. the block
+//puts the fg back after it (setsFg)
+func colorGraphemes(inner []Span, colorAt func(index, total, line, lines int) Color) []Span {
+  total, lines := 0, 1
+  for _, span := range inner{
+    for _, g := range graphemes(span.Text){
+      if g == "\n" {
+        lines++
+      } else {
+        total++
+      }
+    }
+  }
+
+  var (
+    result  []Span
+    index   = 0
+    line    = 0
+    lastTag = ""
+  )
+  for _, span := range inner{
+    if span.Tag != ""{
+      result = append(result, span)
+      lastTag = ""
+      continue
+    }
+    for _, g := range graphemes(span.Text){
+      if g == "\n" {
+        line++
+        result = append(result, Span{Text: g})
+        continue
+      }
+      tag := colorAt(index, total, line, lines).Fg()
+      index++
+      if tag != lastTag && strings.TrimSpace(g) != ""{
+        result = append(result, Span{Tag: tag})
+        lastTag = tag
+      }
+      result = append(result, Span{Text: g})
+    }
+  }
+  return result
+}
+
+func fraction(index, total int) float64 {
+  if total <= 1 {
+    return 0
+  }
+  return float64(index) / float64(total-1)
+}
+
+func gradientBlock(arg string, inner []Span) []Span {
+  stops, _ := gradientStops(arg)
+  return colorGraphemes(inner, func(index, total, line, lines int) Color {
+    return gradientAt(stops, fraction(index, total))
+  })
+}
+
+func verticalGradientBlock(arg string, inner []Span) []Span {
+  stops, _ := gradientStops(arg)
+  return colorGraphemes(inner, func(index, total, line, lines int) Color {
+    return gradientAt(stops, fraction(line, lines))
+  })
+}
+
+func rainbowBlock(arg string, inner []Span) []Span {
+  //even lightness and chroma so no hue stands out
+  return colorGraphemes(inner, func(index, total, line, lines int) Color {
+    return OKLCH(0.75, 0.15, 360*float64(index)/float64(max(total, 1)))
+  })
+}
+
+
+//======================================
+// TEXT BLOCKS
+//======================================
+
+//textBlock changes the text of every span and keeps the tags
+func textBlock(transform func(string) string) TagHandler {
+  return func(arg string, inner []Span) []Span {
+    result := make([]Span, len(inner))
+    for i, span := range inner{
+      result[i] = span
+      if span.Tag == ""{
+        result[i].Text = transform(span.Text)
+      }
+    }
+    return result
+  }
+}
+
+func optionalWidthArg(arg string) error {
+  if arg == ""{
+    return nil
+  }
+  return widthArg(arg)
+}
+
+//spansWidth is how many cells the text of spans takes
+func spansWidth(spans []Span) int {
+  width := 0
+  for _, span := range spans{
+    width += textWidth(span.Text)
+  }
+  return width
+}
+
+//padded puts spaces on either side of the spans, they take the style in effect there
+func padded(left int, inner []Span, right int) []Span {
+  result := make([]Span, 0, len(inner)+2)
+  if left > 0 {
+    result = append(result, Span{Text: strings.Repeat(" ", left)})
+  }
+  result = append(result, inner...)
+  if right > 0 {
+    result = append(result, Span{Text: strings.Repeat(" ", right)})
+  }
+  return result
+}
+
+func padBlock(arg string, inner []Span) []Span {
+  width, _ := strconv.Atoi(arg)
+  return padded(0, inner, width-spansWidth(inner))
+}
+
+func leftPadBlock(arg string, inner []Span) []Span {
+  width, _ := strconv.Atoi(arg)
+  return padded(width-spansWidth(inner), inner, 0)
+}
+
+//centerBlock centers in arg cells, or in the terminal width from COLUMNS (80 without it)
+func centerBlock(arg string, inner []Span) []Span {
+  width, err := strconv.Atoi(arg)
+  if err != nil {
+    if width, err = strconv.Atoi(os.Getenv("COLUMNS")); err != nil || width < 1 {
+      width = 80
+    }
+  }
+  space := max(0, width-spansWidth(inner))
+  return padded(space/2, inner, space-space/2)
+}
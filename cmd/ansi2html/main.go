@@ -0,0 +1,24 @@
+//ansi2html reads terminal output from stdin and writes it as html to stdout
+//
+//  some-command | ansi2html -standalone -classes > out.html
+package main
+
+import (
+  "flag"
+  "fmt"
+  "os"
+
+  "github.com/ph4mished/color"
+)
+
+func main() {
+  classes := flag.Bool("classes", false, "use css classes instead of inline styles")
+  standalone := flag.Bool("standalone", false, "write a whole html document")
+  flag.Parse()
+
+  opts := color.HTMLOptions{Classes: *classes, Standalone: *standalone}
+  if err := color.ANSIToHTML(os.Stdin, os.Stdout, opts); err != nil {
+    fmt.Fprintln(os.Stderr, "ansi2html:", err)
+    os.Exit(1)
+  }
+}
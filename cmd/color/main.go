@@ -0,0 +1,80 @@
+//color is a small command line front end for the library
+//
+//  color svg [flags] < output.txt > screenshot.svg
+package main
+
+import (
+  "flag"
+  "fmt"
+  "io"
+  "os"
+
+  "github.com/ph4mished/color"
+)
+
+func usage() {
+  fmt.Fprintln(os.Stderr, "usage: color <command> [flags]")
+  fmt.Fprintln(os.Stderr, "")
+  fmt.Fprintln(os.Stderr, "commands:")
+  fmt.Fprintln(os.Stderr, "  svg    render terminal output (or markup with -markup) from stdin as an svg image")
+}
+
+func main() {
+  if len(os.Args) < 2 {
+    usage()
+    os.Exit(2)
+  }
+
+  var err error
+  switch os.Args[1] {
+  case "svg":
+    err = runSVG(os.Args[2:])
+  case "-h", "--help", "help":
+    usage()
+    return
+  default:
+    fmt.Fprintf(os.Stderr, "color: unknown command %q\n", os.Args[1])
+    usage()
+    os.Exit(2)
+  }
+
+  if err != nil {
+    fmt.Fprintln(os.Stderr, "color:", err)
+    os.Exit(1)
+  }
+}
+
+func runSVG(args []string) error {
+  flags := flag.NewFlagSet("svg", flag.ExitOnError)
+  window := flags.Bool("window", false, "draw window chrome")
+  title := flags.String("title", "", "window title")
+  font := flags.String("font", "", "font family")
+  size := flags.Float64("size", 14, "font size in px")
+  markup := flags.Bool("markup", false, "read template markup like [fg=red] instead of escape sequences")
+  flags.Parse(args)
+
+  input, err := io.ReadAll(os.Stdin)
+  if err != nil {
+    return err
+  }
+
+  var spans []color.Span
+  if *markup {
+    spans = color.NewColorToggle(true).Parse(string(input)).Spans()
+  } else {
+    var parser color.ANSIParser
+    emit := func(span color.Span) {
+      spans = append(spans, span)
+    }
+    parser.Feed(input, emit)
+    parser.Flush(emit)
+  }
+
+  opts := color.SVGOptions{
+    FontFamily: *font,
+    FontSize:   *size,
+    Window:     *window,
+    Title:      *title,
+  }
+  return color.WriteSVG(os.Stdout, spans, opts)
+}
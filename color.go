@@ -13,21 +13,52 @@ import (
 type TempPart struct {
   Text string
   Index int
+  //style word the part was parsed from ("fg=red", "bold"), empty for text and placeholders
+  Tag string
+  //set for block tags like [gradient=...]...[/gradient], rendered at Apply time
+  block *tagBlock
+  //filters of a placeholder like [0|upper], run on the argument at Apply time
+  filters []boundFilter
+  //for the resets Concat and Wrap put at the end of a piece: the tag the piece left on
+  closes string
 }
 
 type CompiledTemplate struct {
   Parts []TempPart
   TotalLength int
+  //a copy of the toggle the template was parsed with, used by the composition
+  //functions. nil for templates made without Parse
+  toggle *ColorToggle
 }
 
 type ColorToggle struct {
-  EnableColor bool
+  //LevelNone turns color off, every other level renders colors down to what it can show
+  Level ColorLevel
+  //the terminal background, colors with alpha are composited over it
+  //and fg=auto is picked for it when the template has no bg= of its own
+  Background Color
+  //strict mode: when above 0, Parse warns about every fg/bg pair in a template
+  //with a WCAG contrast ratio below it (4.5 is AA for normal text)
+  MinContrast float64
+  //gets the strict mode warnings, they go to stderr when nil
+  Warn func(message string)
+  //when set, Parse swaps every color for one a viewer with this deficiency can tell apart
+  CVDMode CVD
+  //the 16 colors as the terminal theme shows them, LevelBasic16 brings rgb colors
+  //down to the nearest of these. nil is XtermPalette16
+  Palette *Palette16
+
+  //value maps of the TemplateSet parsing with this toggle
+  maps map[string]ValueMap
 }
 
 func autoDetect() bool {
   if _, exists := os.LookupEnv("NO_COLOR"); exists{
     return false	
   }
+  if os.Getenv("TERM") == "dumb" {
+    return false
+  }
   //uncomment after moving to version 1.24
   //return term.isTerminal(int(os.Stdout.Fd()))//{
 
@@ -38,6 +69,7 @@ func autoDetect() bool {
 }
 
 //should auto detect tty by default
+//the level comes from COLORTERM and TERM
 func NewColorToggle(enableColor ...bool) *ColorToggle {
   var colorEnabled bool
   if len(enableColor) > 0{
@@ -45,9 +77,26 @@ func NewColorToggle(enableColor ...bool) *ColorToggle {
   } else {
 	colorEnabled = autoDetect()
   }
-  return &ColorToggle{
-	EnableColor: colorEnabled,
+  if !colorEnabled {
+	return &ColorToggle{Level: LevelNone, Background: detectBackground()}
   }
+
+  level := detectColorLevel()
+  if level == LevelNone {
+	//color was forced on for a dumb terminal
+	level = LevelBasic16
+  }
+  return &ColorToggle{Level: level, Background: detectBackground()}
+}
+
+//explicit control over the color level, only the background is detected
+func NewColorToggleLevel(level ColorLevel) *ColorToggle {
+  return &ColorToggle{Level: level, Background: detectBackground()}
+}
+
+//Enabled reports if the toggle writes any escape codes
+func (toggle *ColorToggle) Enabled() bool {
+  return toggle.Level > LevelNone
 }
 
 
@@ -55,17 +104,41 @@ func (toggle *ColorToggle) Parse(input string) CompiledTemplate {
   if toggle == nil {
 	toggle = NewColorToggle()
   }
-  
+  return toggle.parse(input, toggle.newColorContext())
+}
+
+//parse reads a template that starts with the colors of colors in effect,
+//the ones around a block for its inner text
+func (toggle *ColorToggle) parse(input string, colors colorContext) CompiledTemplate {
   var (
 	contentSequence  = ""
 	inReadSequence   = false
 	parts            []TempPart
 	currentText      = ""
 	allWords          []string
+	skipUntil         = 0
+	sequenceStart     = 0
   )
 
   for i, ch := range input {
+	if i < skipUntil {
+	  continue
+	}
 	char := string(ch)
+	if !inReadSequence && strings.HasPrefix(input[i:], "[raw]"){
+	  //everything up to [/raw] is kept as it is, escape sequences included
+	  if end := strings.Index(input[i+5:], "[/raw]"); end >= 0 {
+		if len(currentText) > 0 {
+		  parts = append(parts, TempPart{Text: currentText, Index: -1})
+		  currentText = ""
+		}
+		if end > 0 {
+		  parts = append(parts, TempPart{Text: input[i+5 : i+5+end], Index: -1})
+		}
+		skipUntil = i + 5 + end + len("[/raw]")
+		continue
+	  }
+	}
 	if char == "[" && !inReadSequence{
 	  //check if the next value is "["
       // [[fg=color]] should never be an escape
@@ -76,6 +149,7 @@ func (toggle *ColorToggle) Parse(input string) CompiledTemplate {
 	  } else {
 		inReadSequence = true
 		contentSequence = ""
+		sequenceStart = i
 		allWords = nil
 
 		if len(currentText) > 0 {
@@ -85,8 +159,14 @@ func (toggle *ColorToggle) Parse(input string) CompiledTemplate {
 	  }
 	} else if ch == ']' && inReadSequence {
 	    inReadSequence = false
+		//block tags take everything up to their closing tag
+		if part, after, ok := toggle.parseBlock(input, sequenceStart, contentSequence, colors); ok {
+		  parts = append(parts, part)
+		  skipUntil = after
+		  continue
+		}
 		//if last word is present, add it
-		allWords = strings.Fields(contentSequence)
+		allWords = tagWords(contentSequence)
 
 		//check if all in [] are colors
 		allColors := len(allWords) > 0
@@ -97,14 +177,14 @@ func (toggle *ColorToggle) Parse(input string) CompiledTemplate {
 		  }
 		}
 		if allColors{
-		  if toggle.EnableColor {
-			for _, w := range allWords{
-			  parts = append(parts, TempPart{Text: ParseColor(w), Index: -1})
-			}
-		  } else {
-			//redirected output or force turn off color
-			parts = append(parts, TempPart{Text: "", Index: -1})
+		  for _, w := range allWords{
+			//with LevelNone (redirected output or force turn off color) the text is empty
+			//the tag is still kept so other renderers can use it
+			parts = append(parts, toggle.tagPart(w, &colors))
 		  }
+		} else if part, ok := toggle.parsePlaceholder(contentSequence, colors); ok {
+		  //a placeholder with styles of its own
+		  parts = append(parts, part)
 		} else {
 			//not a color
 		  if len(contentSequence) > 0 && allDigits(contentSequence){
@@ -134,9 +214,15 @@ func (toggle *ColorToggle) Parse(input string) CompiledTemplate {
 	parts = append(parts, TempPart{Text: currentText, Index: -1})
   }
 
+  if toggle.MinContrast > 0 {
+	toggle.checkContrast(input, parts)
+  }
+
+  settings := *toggle
   return CompiledTemplate{
 	Parts: parts,
 	TotalLength: len(input),
+	toggle: &settings,
   }
 }
 
@@ -147,6 +233,40 @@ func Parse(input string) CompiledTemplate {
 }
   
 
+//tagWords splits the content of [] on spaces, but not inside () or ""
+//so fg=oklch(0.7 0.15 250) and [0|default:"not set"] stay one word
+func tagWords(content string) []string {
+  var (
+	words  []string
+	word   strings.Builder
+	depth  = 0
+	quoted = false
+  )
+  for _, r := range content{
+	switch {
+	case r == '"':
+	  quoted = !quoted
+	case quoted:
+	case r == '(':
+	  depth++
+	case r == ')' && depth > 0:
+	  depth--
+	case unicode.IsSpace(r) && depth == 0:
+	  if word.Len() > 0 {
+		words = append(words, word.String())
+		word.Reset()
+	  }
+	  continue
+	}
+	word.WriteRune(r)
+  }
+  if word.Len() > 0 {
+	words = append(words, word.String())
+  }
+  return words
+}
+
+
 func allDigits(s string) bool {
   for _, r := range s{
 	if !unicode.IsDigit(r){
@@ -169,11 +289,13 @@ func (temp CompiledTemplate) Apply(args ...any) string {
   result.Grow(estimatedSize)
 
   for _, part := range temp.Parts{
-	if part.Index < 0{
+	if part.block != nil {
+	  result.WriteString(part.block.apply(args))
+	} else if part.Index < 0{
 	  result.WriteString(part.Text)
 	} else {
-	  if part.Index < len(args) {
-		result.WriteString(fmt.Sprint(args[part.Index]))
+	  if value, ok := part.value(args); ok {
+		result.WriteString(value)
 	  }
 	}
   }
@@ -2,7 +2,11 @@ package color
 
 import (
 	"fmt"
+	"math"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestExample(t *testing.T){
@@ -46,3 +50,461 @@ func TestForInterpolation(t *testing.T){
 }
 
 //test for internal (unexportable functions) will also be made
+
+func TestDownsample(t *testing.T){
+  if got := parseColorLevel("fg=#FF5733", LevelTrueColor, nil); got != "\033[38;2;255;87;51m" {
+    t.Errorf("truecolor gave %q", got)
+  }
+  if got := parseColorLevel("fg=#FF5733", LevelAnsi256, nil); got != "\033[38;5;202m" {
+    t.Errorf("256 gave %q", got)
+  }
+  if got := parseColorLevel("bg=rgb(0,0,240)", LevelBasic16, nil); got != "\033[44m" {
+    t.Errorf("16 gave %q", got)
+  }
+  if got := parseColorLevel("fg=214", LevelAnsi256, nil); got != "\033[38;5;214m" {
+    t.Errorf("palette gave %q", got)
+  }
+}
+
+func TestColorLevels(t *testing.T){
+  input := "[fg=red]a[fg=196]b[fg=#00FF00]c"
+  cases := map[ColorLevel]string{
+    LevelNone:      "abc",
+    LevelBasic16:   "\033[31ma\033[91mb\033[92mc",
+    LevelAnsi256:   "\033[31ma\033[38;5;196mb\033[38;5;46mc",
+    LevelTrueColor: "\033[31ma\033[38;5;196mb\033[38;2;0;255;0mc",
+  }
+  for level, want := range cases{
+    if got := NewColorToggleLevel(level).Parse(input).Apply(); got != want {
+      t.Errorf("level %v gave %q, want %q", level, got, want)
+    }
+  }
+
+  t.Setenv("NO_COLOR", "")
+  if toggle := NewColorToggle(); toggle.Enabled() {
+    t.Errorf("NO_COLOR should turn color off, got level %v", toggle.Level)
+  }
+  t.Setenv("TERM", "xterm-256color")
+  t.Setenv("COLORTERM", "")
+  if toggle := NewColorToggle(true); toggle.Level != LevelAnsi256 {
+    t.Errorf("expected 256 colors, got level %v", toggle.Level)
+  }
+}
+
+func TestNearest(t *testing.T){
+  cases := []struct{
+    c    Color
+    want uint8
+  }{
+    {Color{128, 128, 128}, 244},
+    {Color{0x76, 0x76, 0x76}, 243},
+    {Color{255, 135, 0}, 208},
+    {Color{10, 10, 10}, 232},
+  }
+  for _, tc := range cases{
+    if got := tc.c.Nearest256(); got != tc.want {
+      t.Errorf("%v gave %d, want %d", tc.c, got, tc.want)
+    }
+  }
+  if got := (Color{250, 10, 10}).Nearest16(XtermPalette16); got != 9 {
+    t.Errorf("red gave %d", got)
+  }
+  //a theme with a darker red moves the match
+  palette := XtermPalette16
+  palette[9] = Color{120, 40, 40}
+  if got := (Color{250, 10, 10}).Nearest16(palette); got != 1 {
+    t.Errorf("red with custom palette gave %d", got)
+  }
+  //and the toggle brings colors down to its own palette
+  toggle := &ColorToggle{Level: LevelBasic16, Palette: &palette}
+  if got := toggle.Parse("[fg=#fa0a0a]x").Apply(); got != "\033[31mx" {
+    t.Errorf("toggle palette gave %q", got)
+  }
+  if got := NewColorToggleLevel(LevelBasic16).Parse("[fg=#fa0a0a]x").Apply(); got != "\033[91mx" {
+    t.Errorf("default palette gave %q", got)
+  }
+}
+
+func TestParseColorSpec(t *testing.T){
+  spec, err := ParseColorSpec("bg=rgb(15,102,224)")
+  if err != nil || spec.Target != TargetBg || spec.Kind != KindRGB || spec.Color != RGB(15, 102, 224) {
+    t.Errorf("rgb spec gave %+v, %v", spec, err)
+  }
+  spec, err = ParseColorSpec("ul=214")
+  if err != nil || spec.Target != TargetUnderline || spec.Kind != KindPalette || spec.Index != 214 {
+    t.Errorf("palette spec gave %+v, %v", spec, err)
+  }
+  spec, err = ParseColorSpec("bold=reset")
+  if err != nil || spec.Kind != KindReset {
+    t.Errorf("reset spec gave %+v, %v", spec, err)
+  }
+
+  //the old checks let all of these through
+  for _, bad := range []string{"fg=#zzzzzz", "fg=abcdefg", "fg=rgb(1,2,300)", "fg=rgb(1,x,3)", "fg=256", "fg=rgb(1,2,3"}{
+    if _, err := ParseColorSpec(bad); err == nil {
+      t.Errorf("%q should not parse", bad)
+    }
+    if IsSupportedColor(bad) {
+      t.Errorf("%q should not be supported", bad)
+    }
+  }
+
+  if got := parseColorLevel("ul=#ff0000", LevelTrueColor, nil); got != "\033[58;2;255;0;0m" {
+    t.Errorf("underline color gave %q", got)
+  }
+}
+
+func TestColorFunctions(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  got := toggle.Parse("[fg=hsl(210,80%,55%) bg=#abc]a[fg=oklch(0.7 0.15 250)]b[bg=hsv(0deg, 1, 1)]c").Apply()
+  want := "\033[38;2;48;140;232m\033[48;2;170;187;204ma\033[38;2;75;163;247mb\033[48;2;255;0;0mc"
+  if got != want {
+    t.Errorf("got %q, want %q", got, want)
+  }
+
+  //degrades like every other color
+  if got := NewColorToggleLevel(LevelBasic16).Parse("[fg=hsl(0,100%,50%)]x").Apply(); got != "\033[91mx" {
+    t.Errorf("16 colors gave %q", got)
+  }
+
+  for _, bad := range []string{"fg=hsl(210,80%)", "fg=hsl(210,180%,50%)", "fg=oklch(0.7 0.15)", "fg=hsv(x,1,1)", "fg=#abcde"}{
+    if IsSupportedColor(bad) {
+      t.Errorf("%q should not be supported", bad)
+    }
+  }
+
+  //rgb takes spaces like the other functions
+  if got := toggle.Parse("[fg=rgb(1, 2, 3)]x[bg=rgba(0 0 255 100%)]y").Apply(); got != "\033[38;2;1;2;3mx\033[48;2;0;0;255my" {
+    t.Errorf("rgb with spaces gave %q", got)
+  }
+
+  //ParseFloat reads NaN and Inf, colors don't
+  nonFinite := []struct{
+    input string
+    want  string
+  }{
+    {"fg=hsl(NaN,80%,55%)", `hue "NaN" is not a finite number`},
+    {"fg=hsv(-Infinity,1,1)", `hue "-Infinity" is not a finite number`},
+    {"fg=hsl(210,NaN%,55%)", `"NaN%" is not a finite number`},
+    {"fg=oklch(Inf 0.1 20)", `"Inf" is not a finite number`},
+    {"fg=oklch(0.7 +Inf 20)", `"+Inf" is not a finite number`},
+    {"fg=rgba(1,2,3,NaN)", `"NaN" is not a finite number`},
+  }
+  for _, tc := range nonFinite{
+    _, err := ParseColorSpec(tc.input)
+    if err == nil || !strings.Contains(err.Error(), tc.want) {
+      t.Errorf("%s gave error %v, want %s", tc.input, err, tc.want)
+    }
+  }
+}
+
+func TestCSSColors(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  got := toggle.Parse("[fg=red]a[fg=tomato]b[fg=css:red]c[bg=rebeccapurple]d").Apply()
+  want := "\033[31ma\033[38;2;255;99;71mb\033[38;2;255;0;0mc\033[48;2;102;51;153md"
+  if got != want {
+    t.Errorf("got %q, want %q", got, want)
+  }
+  if got := NewColorToggleLevel(LevelAnsi256).Parse("[bg=slategray]x").Apply(); got != "\033[48;5;244mx" {
+    t.Errorf("256 gave %q", got)
+  }
+  if IsSupportedColor("fg=css:notacolor") {
+    t.Error("unknown css name should not be supported")
+  }
+  if c, err := Named("css:lightblue"); err != nil || c != RGB(0xad, 0xd8, 0xe6) {
+    t.Errorf("Named gave %v, %v", c, err)
+  }
+}
+
+func TestAlpha(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  toggle.Background = RGB(0, 0, 0)
+
+  //over the toggle background, then over the template bg=
+  temp := toggle.Parse("[fg=#FFFFFF80]a[bg=rgb(0,0,200) fg=rgba(255,255,255,0.5)]b[reset fg=#fff8]c")
+  want := "\033[38;2;128;128;128ma\033[48;2;0;0;200m\033[38;2;128;128;228mb\033[0m\033[38;2;136;136;136mc"
+  if got := temp.Apply(); got != want {
+    t.Errorf("got %q, want %q", got, want)
+  }
+  //renderers see the solid color
+  if got := temp.Render(DebugRenderer{}); got != "<fg=#808080>a<bg=rgb(0,0,200)><fg=#8080e4>b<reset><fg=#888888>c" {
+    t.Errorf("debug gave %q", got)
+  }
+
+  for _, bad := range []string{"fg=rgba(1,2,3)", "fg=rgba(1,2,3,2)", "fg=#1234567"}{
+    if IsSupportedColor(bad) {
+      t.Errorf("%q should not be supported", bad)
+    }
+  }
+}
+
+func TestContrast(t *testing.T){
+  if got := Contrast(RGB(0, 0, 0), RGB(255, 255, 255)); got != 21 {
+    t.Errorf("black on white gave %v", got)
+  }
+  if got := Contrast(RGB(0x76, 0x76, 0x76), RGB(255, 255, 255)); got < 4.5 || got > 4.6 {
+    t.Errorf("#767676 on white gave %v", got)
+  }
+
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  toggle.Background = RGB(0, 0, 0)
+  temp := toggle.Parse("[fg=auto]a[bg=yellow fg=auto]b[bg=navy fg=auto]c")
+  if got := temp.Render(DebugRenderer{}); got != "<fg=#ffffff>a<bg=yellow><fg=#000000>b<bg=navy><fg=#ffffff>c" {
+    t.Errorf("auto gave %q", got)
+  }
+  if IsSupportedColor("bg=auto") {
+    t.Error("bg=auto should not be supported")
+  }
+
+  var warnings []string
+  toggle.MinContrast = 4.5
+  toggle.Warn = func(message string) { warnings = append(warnings, message) }
+  toggle.Parse("[fg=white bg=yellow]low[reset] [fg=black bg=yellow][0] [bg=white fg=#ffffff80]x")
+  if len(warnings) != 2 {
+    t.Fatalf("got warnings %q", warnings)
+  }
+  if !strings.Contains(warnings[0], "fg #e5e5e5 on bg #cdcd00") {
+    t.Errorf("warning was %q", warnings[0])
+  }
+  //styled placeholders and block contents are checked too
+  for _, input := range []string{"[bg=yellow][0 fg=white]", "[bg=yellow][upper][fg=white]x[/upper]"}{
+    warnings = nil
+    toggle.Parse(input)
+    if len(warnings) != 1 {
+      t.Errorf("%s gave warnings %q", input, warnings)
+    }
+  }
+
+  //blocks see the bg around them
+  toggle.MinContrast = 0
+  if got := toggle.Parse("[bg=white][upper][fg=auto]x[/upper]").Render(DebugRenderer{}); got != "<bg=white><fg=#000000>X" {
+    t.Errorf("auto in a block gave %q", got)
+  }
+  if got := toggle.Parse("[bg=#0000ff][upper][fg=#ff000080]x[/upper]").Render(DebugRenderer{}); got != "<bg=#0000ff><fg=#80007f>X" {
+    t.Errorf("alpha in a block gave %q", got)
+  }
+}
+
+func TestCVD(t *testing.T){
+  if got := RGB(205, 0, 0).Simulate(Protanopia); got != RGB(0x56, 0x4b, 0x00) {
+    t.Errorf("simulated red gave %v", got)
+  }
+  if got := RGB(128, 128, 128).Daltonize(Deuteranopia); got != RGB(128, 128, 128) {
+    t.Errorf("gray should not move, got %v", got)
+  }
+
+  //named colors swap at 16 colors and take the safe palette above it
+  basic := NewColorToggleLevel(LevelBasic16)
+  basic.CVDMode = Deuteranopia
+  if got := basic.Parse("[fg=red]a[fg=green]b[bg=2]c").Apply(); got != "\033[31ma\033[34mb\033[44mc" {
+    t.Errorf("16 colors gave %q", got)
+  }
+  //the swaps never merge two of the 16 colors
+  for _, mode := range []CVD{Protanopia, Deuteranopia, Tritanopia}{
+    seen := map[string]string{}
+    for _, name := range namedColors{
+      code := mode.remapTag("fg="+name, LevelBasic16)
+      if other, exists := seen[code]; exists {
+        t.Errorf("%v maps %s and %s both to %s", mode, other, name, code)
+      }
+      seen[code] = name
+    }
+  }
+  truecolor := NewColorToggleLevel(LevelTrueColor)
+  truecolor.CVDMode = Deuteranopia
+  got := truecolor.Parse("[fg=red]a[fg=green]b[bg=#00ff00]c[gradient=#000000:#ffffff]d[/gradient]").Render(DebugRenderer{})
+  if got != "<fg=#d55e00>a<fg=#009e73>b<bg=#008100>c<fg=#000000>d<fg=#009e73>" {
+    t.Errorf("truecolor gave %q", got)
+  }
+
+  preview := Parse("[fg=red]a[fg=#00cd00]b[reset]").Render(CVDRenderer{Mode: Protanopia, Next: DebugRenderer{}})
+  if preview != "<fg=#564b00>a<fg=#d2b800>b<reset>" {
+    t.Errorf("preview gave %q", preview)
+  }
+}
+
+func TestKeyColors(t *testing.T){
+  //every key gets its own slot until the palette is full
+  keys := NewKeyColors([]Color{RGB(1, 0, 0), RGB(2, 0, 0), RGB(3, 0, 0)})
+  seen := map[int]bool{}
+  for _, key := range []string{"api", "db", "worker"}{
+    seen[keys.Index(key)] = true
+  }
+  if len(seen) != 3 || keys.Index("api") != keys.Index("api") {
+    t.Errorf("slots %v", seen)
+  }
+  //the zero value takes KeyPalette, and the palette can change length
+  var zero KeyColors
+  if got := zero.For("api"); !slices.Contains(KeyPalette, got) {
+    t.Errorf("zero KeyColors gave %v", got)
+  }
+  grown := KeyColors{Palette: []Color{RGB(1, 0, 0)}}
+  grown.Index("a")
+  grown.Palette = append(grown.Palette, RGB(2, 0, 0), RGB(3, 0, 0))
+  if first, second := grown.Index("b"), grown.Index("c"); first == second || grown.Index("a") != 0 {
+    t.Errorf("grown palette gave slots %d and %d", first, second)
+  }
+  grown.Palette = grown.Palette[:1]
+  if got := grown.Index("c"); got != 0 {
+    t.Errorf("shrunk palette gave slot %d", got)
+  }
+  saved := KeyPalette
+  KeyPalette = nil
+  if ForKey("api", nil) != (Color{}) || zero.For("api") != (Color{}) {
+    t.Error("an empty KeyPalette should give the zero color")
+  }
+  KeyPalette = saved
+
+  //ForKey is the hash slot alone, whatever was asked for before
+  palette := []Color{RGB(1, 0, 0), RGB(2, 0, 0), RGB(3, 0, 0)}
+  for _, key := range []string{"db", "worker", "api"}{
+    if got := ForKey(key, palette); got != palette[keySlot(key, 3)] {
+      t.Errorf("ForKey(%q) gave %v", key, got)
+    }
+  }
+
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  temp := toggle.Parse("[fg=white][0 fg=hash bold]: [1]")
+  want := "<fg=white><" + ForKey("api", nil).Fg() + "><bold>api<fg=white><bold=reset>: up"
+  if got := temp.Render(DebugRenderer{}, "api", "up"); got != want {
+    t.Errorf("got %q, want %q", got, want)
+  }
+  //dim=reset also ends bold (both are SGR 22), so the template's bold comes back
+  if got := NewColorToggleLevel(LevelBasic16).Parse("[bold]a [0 dim] b").Apply("X"); got != "\033[1ma \033[2mX\033[22m\033[1m b" {
+    t.Errorf("dim inside bold gave %q", got)
+  }
+  //named colors at 16 colors
+  got := NewColorToggleLevel(LevelBasic16).Parse("[0 fg=hash]").Render(DebugRenderer{}, "api")
+  if !strings.HasPrefix(got, "<fg=") || strings.Contains(got, "#") {
+    t.Errorf("16 colors gave %q", got)
+  }
+  //a styled placeholder with an unknown word stays text
+  if got := toggle.Parse("[0 fg=nope]").Apply("x"); got != "[0 fg=nope]" {
+    t.Errorf("bad placeholder gave %q", got)
+  }
+}
+
+func TestScales(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  score := toggle.Parse("[0 scale=90:green,70:yellow,0:red bold]")
+  for value, want := range map[any]string{
+    95:    "<fg=green><bold>95<fg=reset><bold=reset>",
+    70:    "<fg=yellow><bold>70<fg=reset><bold=reset>",
+    "45%": "<fg=red><bold>45%<fg=reset><bold=reset>",
+    -1:    "<bold>-1<bold=reset>",
+    "n/a": "<bold>n/a<bold=reset>",
+  }{
+    if got := score.Render(DebugRenderer{}, value); got != want {
+      t.Errorf("%v gave %q, want %q", value, got, want)
+    }
+  }
+
+  //NaN and the infinities are not numbers, for scales and heat alike
+  heat := toggle.Parse("[0 heat=0:100:#ff0000:#0000ff]")
+  for _, value := range []any{math.NaN(), "NaN", math.Inf(1), "-Inf"}{
+    if got := score.Render(DebugRenderer{}, value); got != "<bold>"+fmt.Sprint(value)+"<bold=reset>" {
+      t.Errorf("scale of %v gave %q", value, got)
+    }
+    if got := heat.Render(DebugRenderer{}, value); got != fmt.Sprint(value) {
+      t.Errorf("heat of %v gave %q", value, got)
+    }
+  }
+  stops := []Color{RGB(255, 0, 0), RGB(0, 0, 255)}
+  if gradientAt(stops, math.NaN()) != stops[0] || gradientAt(stops, math.Inf(1)) != stops[1] {
+    t.Error("gradientAt is not clamped")
+  }
+  //a step can add styles to its color
+  styled := toggle.Parse("[0 scale=90:green+bold+italic,0:red]")
+  if got := styled.Render(DebugRenderer{}, 95); got != "<fg=green><bold><italic>95<fg=reset><bold=reset><italic=reset>" {
+    t.Errorf("styled step gave %q", got)
+  }
+  if got := styled.Render(DebugRenderer{}, 5); got != "<fg=red>5<fg=reset>" {
+    t.Errorf("plain step gave %q", got)
+  }
+
+  if got := heat.Render(DebugRenderer{}, 50); got != "<fg=#8c53a2>50<fg=reset>" {
+    t.Errorf("heat gave %q", got)
+  }
+  if got := heat.Apply(500); got != "\033[38;2;0;0;255m500\033[39m" {
+    t.Errorf("heat above the range gave %q", got)
+  }
+
+  for _, bad := range []string{"[0 scale=90]", "[0 scale=x:red]", "[0 scale=NaN:red]", "[0 scale=90:red+blue]", "[0 heat=0:0:red:blue]", "[0 heat=0:1:red]", "[0 heat=0:Inf:red:blue]"}{
+    if got := toggle.Parse(bad).Apply(1); got != bad {
+      t.Errorf("%s gave %q", bad, got)
+    }
+  }
+}
+
+func TestValueMaps(t *testing.T){
+  set := NewTemplateSet(NewColorToggleLevel(LevelTrueColor)).Map("status", map[string]string{
+    "Online":  "fg=green bold",
+    "Offline": "fg=red bold",
+  }, "fg=yellow")
+  temp := set.Parse("[fg=white][0] [1 map=status]!")
+  for value, want := range map[string]string{
+    "Online":   "<fg=white>db <fg=green><bold>Online<fg=white><bold=reset>!",
+    "Degraded": "<fg=white>db <fg=yellow>Degraded<fg=white>!",
+  }{
+    if got := temp.Render(DebugRenderer{}, "db", value); got != want {
+      t.Errorf("%s gave %q, want %q", value, got, want)
+    }
+  }
+
+  inline := NewColorToggleLevel(LevelBasic16).Parse("[0 map=(ok: fg=rgb(0,200,0), fail: fg=red)]")
+  if got := inline.Apply("fail"); got != "\033[31mfail\033[39m" {
+    t.Errorf("inline gave %q", got)
+  }
+  if got := inline.Apply("other"); got != "other" {
+    t.Errorf("no default gave %q", got)
+  }
+
+  //unknown maps and bad styles stay text
+  for _, bad := range []string{"[0 map=nope]", "[0 map=(ok: fg=nope)]", "[0 map=(oops)]"}{
+    if got := set.Parse(bad).Apply("ok"); got != bad {
+      t.Errorf("%s gave %q", bad, got)
+    }
+  }
+}
+
+func TestFilters(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  temp := toggle.Parse(`[0|upper|pad:6]|[1|ltrunc:12]|[2|bytes]|[3|duration]|[4|default:"not set"]|[5|trunc:5 fg=red]`)
+  got := temp.Render(DebugRenderer{}, "info", "/usr/local/lib/go/src", 1536, 1500*time.Millisecond, "", "abcdefgh")
+  want := "INFO  |…/lib/go/src|1.5 KiB|1.5s|not set|<fg=red>abcd…<fg=reset>"
+  if got != want {
+    t.Errorf("got %q, want %q", got, want)
+  }
+  //the unit is picked after rounding
+  sizes := map[float64]string{1024*1024 - 1: "1 MiB", 1023: "1023 B", 1024*1024*1.04: "1 MiB", -(1024*1024 - 1): "-1 MiB"}
+  for size, want := range sizes{
+    if got := bytesFilter(size, ""); got != want {
+      t.Errorf("bytes of %v gave %q, want %q", size, got, want)
+    }
+  }
+  //default also covers a missing argument
+  if got := toggle.Parse(`[0|default:n/a]`).Apply(); got != "n/a" {
+    t.Errorf("missing argument gave %q", got)
+  }
+
+  RegisterFilter("reverse", func(value any, arg string) any {
+    runes := []rune(fmt.Sprint(value))
+    slices.Reverse(runes)
+    return string(runes)
+  })
+  //the registry is global, leave it as it was for the other tests
+  t.Cleanup(func() {
+    filtersMu.Lock()
+    defer filtersMu.Unlock()
+    delete(filters, "reverse")
+  })
+  if got := toggle.Parse("[0|reverse|upper]").Apply("abc"); got != "CBA" {
+    t.Errorf("registered filter gave %q", got)
+  }
+
+  for _, bad := range []string{"[0|nope]", "[0|trunc:x]", "[0|upper:x]"}{
+    if got := toggle.Parse(bad).Apply("v"); got != bad {
+      t.Errorf("%s gave %q", bad, got)
+    }
+  }
+}
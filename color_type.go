@@ -0,0 +1,310 @@
+package color
+
+import (
+  "errors"
+  "fmt"
+  "math"
+  "strconv"
+  "strings"
+)
+
+//===========================================
+//  COLOR VALUES
+//===========================================
+
+//Color is an sRGB color value. it can be turned into markup with Fg/Bg
+//or straight into an escape sequence with FgSGR/BgSGR
+type Color struct {
+  R, G, B uint8
+}
+
+//RGB makes a color from its red, green and blue channels
+func RGB(r, g, b uint8) Color {
+  return Color{r, g, b}
+}
+
+//Hex reads "#rrggbb", "rrggbb", "#rgb" or "rgb"
+func Hex(hex string) (Color, error) {
+  digits := strings.TrimPrefix(hex, "#")
+  if len(digits) == 3 {
+    digits = string([]byte{digits[0], digits[0], digits[1], digits[1], digits[2], digits[2]})
+  }
+  if len(digits) != 6 {
+    return Color{}, fmt.Errorf("color: %q is not a hex color", hex)
+  }
+  value, err := strconv.ParseUint(digits, 16, 32)
+  if err != nil {
+    return Color{}, fmt.Errorf("color: %q is not a hex color", hex)
+  }
+  return Color{uint8(value >> 16), uint8(value >> 8), uint8(value)}, nil
+}
+
+//Ansi256 returns the xterm default rgb of a 256 palette index
+func Ansi256(index uint8) Color {
+  r, g, b := paletteRGB(int(index))
+  return Color{r, g, b}
+}
+
+//Named returns the xterm default rgb of one of the 16 named colors ("red", "lightblue"),
+//or a css/x11 color ("tomato"). "css:red" gives the css value of a name both share
+func Named(name string) (Color, error) {
+  if cssName, isCSS := strings.CutPrefix(name, "css:"); isCSS {
+    if c, exists := CSSColors[cssName]; exists {
+      return c, nil
+    }
+    return Color{}, errors.New("color: unknown css color name " + strconv.Quote(cssName))
+  }
+  for i, named := range namedColors{
+    if named == name {
+      return XtermPalette16[i], nil
+    }
+  }
+  if c, exists := CSSColors[name]; exists {
+    return c, nil
+  }
+  return Color{}, errors.New("color: unknown color name " + strconv.Quote(name))
+}
+
+//HSL makes a color from hue (degrees), saturation and lightness (0-1)
+func HSL(h, s, l float64) Color {
+  s, l = clamp01(s), clamp01(l)
+  chroma := (1 - math.Abs(2*l-1)) * s
+  return hueColor(h, chroma, l-chroma/2)
+}
+
+//HSV makes a color from hue (degrees), saturation and value (0-1)
+func HSV(h, s, v float64) Color {
+  s, v = clamp01(s), clamp01(v)
+  chroma := v * s
+  return hueColor(h, chroma, v-chroma)
+}
+
+//hueColor is the shared end of HSL and HSV
+func hueColor(h, chroma, m float64) Color {
+  h = math.Mod(h, 360)
+  if h < 0 {
+    h += 360
+  }
+  x := chroma * (1 - math.Abs(math.Mod(h/60, 2)-1))
+  var r, g, b float64
+  switch {
+  case h < 60:
+    r, g, b = chroma, x, 0
+  case h < 120:
+    r, g, b = x, chroma, 0
+  case h < 180:
+    r, g, b = 0, chroma, x
+  case h < 240:
+    r, g, b = 0, x, chroma
+  case h < 300:
+    r, g, b = x, 0, chroma
+  default:
+    r, g, b = chroma, 0, x
+  }
+  return Color{channel(r + m), channel(g + m), channel(b + m)}
+}
+
+//OKLab makes a color from OKLab coordinates, out of gamut values are clipped
+func OKLab(l, a, b float64) Color {
+  c, _ := fromOKLab(l, a, b)
+  return c
+}
+
+//OKLCH makes a color from OKLCH lightness (0-1), chroma and hue (degrees).
+//out of gamut colors keep their lightness and hue and lose chroma until they fit
+func OKLCH(l, c, h float64) Color {
+  rad := h * math.Pi / 180
+  if color, ok := fromOKLab(l, c*math.Cos(rad), c*math.Sin(rad)); ok {
+    return color
+  }
+  low, high := 0.0, c
+  for i := 0; i < 24; i++ {
+    mid := (low + high) / 2
+    if _, ok := fromOKLab(l, mid*math.Cos(rad), mid*math.Sin(rad)); ok {
+      low = mid
+    } else {
+      high = mid
+    }
+  }
+  color, _ := fromOKLab(l, low*math.Cos(rad), low*math.Sin(rad))
+  return color
+}
+
+//fromOKLab converts back to srgb, ok is false when the color had to be clipped
+func fromOKLab(L, a, b float64) (Color, bool) {
+  l := L + 0.3963377774*a + 0.2158037573*b
+  m := L - 0.1055613458*a - 0.0638541728*b
+  s := L - 0.0894841775*a - 1.2914855480*b
+  l, m, s = l*l*l, m*m*m, s*s*s
+
+  linear := [3]float64{
+    4.0767416621*l - 3.3077115913*m + 0.2309699292*s,
+    -1.2684380046*l + 2.6097574011*m - 0.3413193965*s,
+    -0.0041960863*l - 0.7034186147*m + 1.7076147010*s,
+  }
+  ok := true
+  var out [3]uint8
+  for i, v := range linear{
+    if v < -0.0001 || v > 1.0001 {
+      ok = false
+    }
+    out[i] = fromLinear(v)
+  }
+  return Color{out[0], out[1], out[2]}, ok
+}
+
+func clamp01(v float64) float64 {
+  return math.Max(0, math.Min(1, v))
+}
+
+//fromLinear turns linear light back into an srgb channel
+func fromLinear(v float64) uint8 {
+  v = clamp01(v)
+  if v <= 0.0031308 {
+    return channel(v * 12.92)
+  }
+  return channel(1.055*math.Pow(v, 1/2.4) - 0.055)
+}
+
+//channel turns 0-1 into 0-255
+func channel(v float64) uint8 {
+  return uint8(math.Round(clamp01(v) * 255))
+}
+
+
+//======================================
+// CONVERSIONS
+//======================================
+
+//String returns "#rrggbb"
+func (c Color) String() string {
+  return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
+
+//HSL returns hue (degrees), saturation and lightness (0-1)
+func (c Color) HSL() (h, s, l float64) {
+  r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+  max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+  l = (max + min) / 2
+  if max == min {
+    return 0, 0, l
+  }
+  d := max - min
+  s = d / (1 - math.Abs(2*l-1))
+  return hue(r, g, b, max, d), s, l
+}
+
+//HSV returns hue (degrees), saturation and value (0-1)
+func (c Color) HSV() (h, s, v float64) {
+  r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+  max, min := math.Max(r, math.Max(g, b)), math.Min(r, math.Min(g, b))
+  if max == 0 {
+    return 0, 0, 0
+  }
+  d := max - min
+  if d == 0 {
+    return 0, 0, max
+  }
+  return hue(r, g, b, max, d), d / max, max
+}
+
+func hue(r, g, b, max, d float64) float64 {
+  var h float64
+  switch max {
+  case r:
+    h = math.Mod((g-b)/d, 6)
+  case g:
+    h = (b-r)/d + 2
+  default:
+    h = (r-g)/d + 4
+  }
+  h *= 60
+  if h < 0 {
+    h += 360
+  }
+  return h
+}
+
+//OKLab returns the OKLab coordinates
+func (c Color) OKLab() (l, a, b float64) {
+  lab := c.oklab()
+  return lab[0], lab[1], lab[2]
+}
+
+//OKLCH returns OKLCH lightness (0-1), chroma and hue (degrees)
+func (c Color) OKLCH() (l, chroma, h float64) {
+  l, a, b := c.OKLab()
+  chroma = math.Hypot(a, b)
+  h = math.Atan2(b, a) * 180 / math.Pi
+  if h < 0 {
+    h += 360
+  }
+  return l, chroma, h
+}
+
+
+//======================================
+// OPERATIONS
+//======================================
+
+//Lighten raises OKLCH lightness by amount (0-1), so steps look even across hues
+func (c Color) Lighten(amount float64) Color {
+  l, chroma, h := c.OKLCH()
+  return OKLCH(clamp01(l+amount), chroma, h)
+}
+
+//Darken lowers OKLCH lightness by amount (0-1)
+func (c Color) Darken(amount float64) Color {
+  return c.Lighten(-amount)
+}
+
+//Saturate scales OKLCH chroma by 1+amount, negative amounts desaturate
+func (c Color) Saturate(amount float64) Color {
+  l, chroma, h := c.OKLCH()
+  return OKLCH(l, math.Max(0, chroma*(1+amount)), h)
+}
+
+//Mix blends towards other by t (0 = c, 1 = other) in OKLab
+func (c Color) Mix(other Color, t float64) Color {
+  a, b := c.oklab(), other.oklab()
+  return OKLab(a[0]+(b[0]-a[0])*t, a[1]+(b[1]-a[1])*t, a[2]+(b[2]-a[2])*t)
+}
+
+//Blend composites c with the given opacity (0-1) over bg
+func (c Color) Blend(bg Color, alpha float64) Color {
+  alpha = clamp01(alpha)
+  mix := func(top, bottom uint8) uint8 {
+    return uint8(math.Round(float64(top)*alpha + float64(bottom)*(1-alpha)))
+  }
+  return Color{mix(c.R, bg.R), mix(c.G, bg.G), mix(c.B, bg.B)}
+}
+
+//Invert returns the rgb complement
+func (c Color) Invert() Color {
+  return Color{255 - c.R, 255 - c.G, 255 - c.B}
+}
+
+
+//======================================
+// MARKUP
+//======================================
+
+//Fg returns the foreground markup, like "fg=#rrggbb"
+func (c Color) Fg() string {
+  return "fg=" + c.String()
+}
+
+//Bg returns the background markup, like "bg=#rrggbb"
+func (c Color) Bg() string {
+  return "bg=" + c.String()
+}
+
+//FgSGR returns the foreground escape sequence for the level
+func (c Color) FgSGR(level ColorLevel) string {
+  return rgbToAnsiCode(false, c.R, c.G, c.B, level, nil)
+}
+
+//BgSGR returns the background escape sequence for the level
+func (c Color) BgSGR(level ColorLevel) string {
+  return rgbToAnsiCode(true, c.R, c.G, c.B, level, nil)
+}
@@ -0,0 +1,95 @@
+package color
+
+import (
+  "math"
+  "testing"
+)
+
+func TestColorConversions(t *testing.T){
+  c, err := Hex("#abc")
+  if err != nil || c != RGB(0xaa, 0xbb, 0xcc) {
+    t.Fatalf("short hex gave %v, %v", c, err)
+  }
+  if _, err := Hex("#abcd"); err == nil {
+    t.Error("expected an error for a bad hex")
+  }
+
+  //round trips through every space
+  orange := RGB(255, 87, 51)
+  if got := HSL(orange.HSL()); got != orange {
+    t.Errorf("hsl round trip gave %v", got)
+  }
+  if got := HSV(orange.HSV()); got != orange {
+    t.Errorf("hsv round trip gave %v", got)
+  }
+  if got := OKLCH(orange.OKLCH()); got != orange {
+    t.Errorf("oklch round trip gave %v", got)
+  }
+  if l, _, _ := RGB(255, 255, 255).OKLab(); math.Abs(l-1) > 0.001 {
+    t.Errorf("white has lightness %v", l)
+  }
+
+  if got := RGB(0, 0, 0).Mix(RGB(255, 255, 255), 0); got != RGB(0, 0, 0) {
+    t.Errorf("mix at 0 gave %v", got)
+  }
+  if got := orange.Invert(); got != RGB(0, 168, 204) {
+    t.Errorf("invert gave %v", got)
+  }
+  before, _, _ := orange.OKLCH()
+  after, _, _ := orange.Lighten(0.1).OKLCH()
+  if after <= before {
+    t.Errorf("lighten went from %v to %v", before, after)
+  }
+
+  red, _ := Named("red")
+  if red.Fg() != "fg=#cd0000" || Ansi256(196).Bg() != "bg=#ff0000" {
+    t.Errorf("markup gave %s and %s", red.Fg(), Ansi256(196).Bg())
+  }
+  if got := orange.FgSGR(LevelTrueColor); got != "\033[38;2;255;87;51m" {
+    t.Errorf("sgr gave %q", got)
+  }
+}
+
+func TestPalettes(t *testing.T){
+  brand := RGB(0x0f, 0x66, 0xe0)
+  _, _, hue := brand.OKLCH()
+  if _, _, opposite := brand.Complementary().OKLCH(); math.Abs(math.Mod(opposite-hue+360, 360)-180) > 1 {
+    t.Errorf("complementary hue is %v, brand is %v", opposite, hue)
+  }
+  if got := brand.Triadic(); len(got) != 3 || got[0] != brand {
+    t.Errorf("triadic gave %v", got)
+  }
+
+  //even lightness steps, lightest first, a mid tone brand at 500
+  tones := brand.Tones()
+  if tones[500] != brand || len(tones) != len(ToneSteps) {
+    t.Fatalf("tones gave %v", tones)
+  }
+  //every color takes the step of its lightness, near white or black too
+  extremes := map[string]int{brand.String(): 500, "#ffffff": 50, "#fafafa": 50, "#050505": 900, "#ffff00": 50, "#0000ff": 600}
+  for hex, want := range extremes{
+    c, _ := Hex(hex)
+    tones := c.Tones()
+    if tones[want] != c {
+      t.Errorf("%s is not at tone %d: %v", hex, want, tones)
+    }
+    last := 2.0
+    for _, step := range ToneSteps{
+      l, _, _ := tones[step].OKLCH()
+      if l >= last {
+        t.Errorf("%s: tone %d (%v) is not darker than the one before", hex, step, tones[step])
+      }
+      last = l
+    }
+  }
+
+  theme := NewTheme(brand.Analogous())
+  if theme.Primary != brand.Analogous()[0] || theme.Roles()["error"] != theme.Error {
+    t.Errorf("theme gave %+v", theme)
+  }
+  for _, theme := range []Theme{theme, NewDarkTheme([]Color{brand})}{
+    if ratio := Contrast(theme.Text, theme.Background); ratio < 7 {
+      t.Errorf("text contrast is only %.2f", ratio)
+    }
+  }
+}
@@ -0,0 +1,415 @@
+package color
+
+import (
+  "fmt"
+  "math"
+  "strconv"
+  "strings"
+)
+
+//===========================================
+//  COLOR SPEC GRAMMAR
+//===========================================
+
+/* one word inside [] is a spec:
+
+     spec    = style | reset | target "=" value
+     target  = "fg" | "bg" | "ul"              (ul is the underline color)
+     value   = "reset" | name | "css:" cssname | index | hex | rgb | rgba | hsl | hsv | oklch
+             | "auto"                           (fg only: black or white, whichever reads best on the bg)
+     name    = one of the 16 ansi names, or a css/x11 name that isn't one of them
+     index   = 0..255                           (256 palette)
+     hex     = "#" 6 or 3 hex digits, 8 or 4 with alpha
+     rgb     = "rgb(" 0..255 "," 0..255 "," 0..255 ")"
+     rgba    = "rgba(" 0..255 "," 0..255 "," 0..255 "," alpha ")"
+     alpha   = 0..1 or 0%..100%
+     hsl     = "hsl(" hue "," percent "," percent ")"
+     hsv     = "hsv(" hue "," percent "," percent ")"
+     oklch   = "oklch(" lightness chroma hue ")"
+     hue     = degrees, "deg" is optional
+     percent = 0%..100% or 0..1
+
+   values inside () may be split by commas, spaces or both
+
+   styles and resets are the words in StyleMap and ResetMap */
+
+//ColorTarget is what a spec colors
+type ColorTarget int
+
+const (
+  TargetNone ColorTarget = iota //styles and the full reset
+  TargetFg
+  TargetBg
+  TargetUnderline
+)
+
+//ColorKind is how a spec was written
+type ColorKind int
+
+const (
+  KindStyle   ColorKind = iota //bold, underline=single...
+  KindReset                    //reset, fg=reset, bold=reset...
+  KindNamed                    //fg=red
+  KindPalette                  //fg=214
+  KindHex                      //fg=#aabbcc
+  KindRGB                      //fg=rgb(1,2,3), fg=rgba(1,2,3,0.5)
+  KindHSL                      //fg=hsl(210,80%,55%)
+  KindHSV                      //fg=hsv(210,80%,55%)
+  KindOKLCH                    //fg=oklch(0.7 0.15 250)
+  KindCSS                      //fg=tomato, fg=css:red
+  KindAuto                     //fg=auto, picked by the parser from the background in effect
+)
+
+//ColorSpec is one parsed word of a template tag
+type ColorSpec struct {
+  Target ColorTarget
+  Kind   ColorKind
+  //the style or reset word ("bold", "fg=reset") or the color name ("red", "tomato")
+  Name string
+  //palette index for KindNamed (0-15) and KindPalette
+  Index uint8
+  //rgb value of the color. named and palette colors use the xterm defaults
+  Color Color
+  //opacity (0-1) for #RRGGBBAA and rgba() colors, 1 for every other color
+  Alpha float64
+}
+
+var targetPrefixes = map[string]ColorTarget{
+  "fg": TargetFg,
+  "bg": TargetBg,
+  "ul": TargetUnderline,
+}
+
+//ParseColorSpec reads one word like "bold", "fg=red", "bg=214" or "ul=#ff0000"
+func ParseColorSpec(s string) (ColorSpec, error) {
+  if _, exists := StyleMap[s]; exists{
+    return ColorSpec{Kind: KindStyle, Name: s}, nil
+  }
+  if _, exists := ResetMap[s]; exists{
+    target := TargetNone
+    if prefix, _, found := strings.Cut(s, "="); found {
+      target = targetPrefixes[prefix]
+    }
+    return ColorSpec{Target: target, Kind: KindReset, Name: s}, nil
+  }
+
+  prefix, value, found := strings.Cut(s, "=")
+  target, isTarget := targetPrefixes[prefix]
+  if !found || !isTarget {
+    return ColorSpec{}, fmt.Errorf("color: %q is not a style or a color (colors start with fg=, bg= or ul=)", s)
+  }
+  if value == "" {
+    return ColorSpec{}, fmt.Errorf("color: %q has no color after %s=", s, prefix)
+  }
+
+  spec := ColorSpec{Target: target, Alpha: 1}
+  switch {
+  case value == "auto":
+    if target != TargetFg {
+      return ColorSpec{}, fmt.Errorf("color: %q: only fg= can be auto", s)
+    }
+    spec.Kind = KindAuto
+
+  case value[0] == '#':
+    c, alpha, err := parseHexValue(value)
+    if err != nil {
+      return ColorSpec{}, fmt.Errorf("color: %q: %v", s, err)
+    }
+    spec.Kind, spec.Color, spec.Alpha = KindHex, c, alpha
+
+  case strings.HasPrefix(value, "rgb("), strings.HasPrefix(value, "rgba("):
+    c, alpha, err := parseRGBValue(value)
+    if err != nil {
+      return ColorSpec{}, fmt.Errorf("color: %q: %v", s, err)
+    }
+    spec.Kind, spec.Color, spec.Alpha = KindRGB, c, alpha
+
+  case strings.HasPrefix(value, "hsl("), strings.HasPrefix(value, "hsv("), strings.HasPrefix(value, "oklch("):
+    kind, c, err := parseFunctionValue(value)
+    if err != nil {
+      return ColorSpec{}, fmt.Errorf("color: %q: %v", s, err)
+    }
+    spec.Kind, spec.Color = kind, c
+
+  case value[0] >= '0' && value[0] <= '9':
+    index, err := strconv.Atoi(value)
+    if err != nil {
+      return ColorSpec{}, fmt.Errorf("color: %q: palette index must be a number", s)
+    }
+    if index > 255 {
+      return ColorSpec{}, fmt.Errorf("color: %q: palette index %d is out of range 0-255", s, index)
+    }
+    spec.Kind, spec.Index, spec.Color = KindPalette, uint8(index), Ansi256(uint8(index))
+
+  case strings.HasPrefix(value, "css:"):
+    name := value[len("css:"):]
+    c, exists := CSSColors[name]
+    if !exists {
+      return ColorSpec{}, fmt.Errorf("color: %q: unknown css color name %q", s, name)
+    }
+    spec.Kind, spec.Name, spec.Color = KindCSS, name, c
+
+  default:
+    //the ansi names keep their terminal palette codes
+    for i, name := range namedColors{
+      if name == value {
+        spec.Kind, spec.Name, spec.Index, spec.Color = KindNamed, name, uint8(i), XtermPalette16[i]
+        return spec, nil
+      }
+    }
+    if c, exists := CSSColors[value]; exists {
+      spec.Kind, spec.Name, spec.Color = KindCSS, value, c
+      return spec, nil
+    }
+    return ColorSpec{}, fmt.Errorf("color: %q: unknown color name %q", s, value)
+  }
+  return spec, nil
+}
+
+func parseHexValue(value string) (Color, float64, error) {
+  digits := value[1:]
+  for _, r := range digits{
+    if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+      return Color{}, 0, fmt.Errorf("%q is not a hex digit", r)
+    }
+  }
+
+  alpha := 1.0
+  switch len(digits) {
+  case 3, 6:
+  case 4, 8:
+    //the last digit (#rgba) or pair (#rrggbbaa) is alpha
+    alphaDigits := digits[len(digits)*3/4:]
+    if len(alphaDigits) == 1 {
+      alphaDigits += alphaDigits
+    }
+    a, _ := strconv.ParseUint(alphaDigits, 16, 8)
+    alpha = float64(a) / 255
+    digits = digits[:len(digits)*3/4]
+  default:
+    return Color{}, 0, fmt.Errorf("hex color needs 3, 4, 6 or 8 digits, got %d", len(digits))
+  }
+  c, err := Hex(digits)
+  return c, alpha, err
+}
+
+func parseRGBValue(value string) (Color, float64, error) {
+  name, args, _ := strings.Cut(value, "(")
+  if !strings.HasSuffix(args, ")") {
+    return Color{}, 0, fmt.Errorf("%s( is missing its closing )", name)
+  }
+  fields := functionArgs(args[:len(args)-1])
+
+  alpha := 1.0
+  if name == "rgba" {
+    if len(fields) != 4 {
+      return Color{}, 0, fmt.Errorf("rgba needs 4 values, got %d", len(fields))
+    }
+    var err error
+    if alpha, err = parsePercent(fields[3], 1); err != nil {
+      return Color{}, 0, fmt.Errorf("alpha %v", err)
+    }
+    fields = fields[:3]
+  }
+
+  numbers, err := readRGB(fields)
+  if err != nil {
+    return Color{}, 0, err
+  }
+  return Color{uint8(numbers[0]), uint8(numbers[1]), uint8(numbers[2])}, alpha, nil
+}
+
+//parseFunctionValue reads hsl(...), hsv(...) and oklch(...)
+func parseFunctionValue(value string) (ColorKind, Color, error) {
+  name, args, _ := strings.Cut(value, "(")
+  if !strings.HasSuffix(args, ")") {
+    return 0, Color{}, fmt.Errorf("%s( is missing its closing )", name)
+  }
+  fields := functionArgs(args[:len(args)-1])
+  if len(fields) != 3 {
+    return 0, Color{}, fmt.Errorf("%s needs 3 values, got %d", name, len(fields))
+  }
+
+  h, err := parseHue(fields[0])
+  if name == "oklch" {
+    h, err = parseHue(fields[2])
+  }
+  if err != nil {
+    return 0, Color{}, err
+  }
+
+  switch name {
+  case "hsl", "hsv":
+    sat, err := parsePercent(fields[1], 1)
+    if err != nil {
+      return 0, Color{}, err
+    }
+    third, err := parsePercent(fields[2], 1)
+    if err != nil {
+      return 0, Color{}, err
+    }
+    if name == "hsl" {
+      return KindHSL, HSL(h, sat, third), nil
+    }
+    return KindHSV, HSV(h, sat, third), nil
+  }
+
+  lightness, err := parsePercent(fields[0], 1)
+  if err != nil {
+    return 0, Color{}, err
+  }
+  //css takes 100% chroma as 0.4
+  chroma, err := parsePercent(fields[1], 0.4)
+  if err != nil {
+    return 0, Color{}, err
+  }
+  return KindOKLCH, OKLCH(lightness, chroma, h), nil
+}
+
+func parseHue(field string) (float64, error) {
+  h, err := strconv.ParseFloat(strings.TrimSuffix(field, "deg"), 64)
+  if err != nil {
+    return 0, fmt.Errorf("hue %q is not a number", field)
+  }
+  if !isFinite(h) {
+    return 0, fmt.Errorf("hue %q is not a finite number", field)
+  }
+  return h, nil
+}
+
+//isFinite reports if v is neither NaN nor infinite, ParseFloat takes "NaN" and "Inf"
+func isFinite(v float64) bool {
+  return !math.IsNaN(v) && !math.IsInf(v, 0)
+}
+
+//parsePercent reads "55%" or a plain number. full is what 100% stands for,
+//plain numbers can't go over it
+func parsePercent(field string, full float64) (float64, error) {
+  if number, isPercent := strings.CutSuffix(field, "%"); isPercent {
+    value, err := strconv.ParseFloat(number, 64)
+    if err == nil && !isFinite(value) {
+      return 0, fmt.Errorf("%q is not a finite number", field)
+    }
+    if err != nil || value < 0 || value > 100 {
+      return 0, fmt.Errorf("%q is not a percentage in 0%%-100%%", field)
+    }
+    return value / 100 * full, nil
+  }
+  value, err := strconv.ParseFloat(field, 64)
+  if err == nil && !isFinite(value) {
+    return 0, fmt.Errorf("%q is not a finite number", field)
+  }
+  if err != nil || value < 0 || value > full {
+    return 0, fmt.Errorf("%q is not a number in 0-%g", field, full)
+  }
+  return value, nil
+}
+
+//functionArgs splits the values inside () on commas, spaces or both
+func functionArgs(args string) []string {
+  return strings.FieldsFunc(args, func(r rune) bool {
+    return r == ',' || r == ' ' || r == '\t'
+  })
+}
+
+//readRGB reads the r, g and b fields with every value in 0..255
+func readRGB(fields []string) ([]int, error) {
+  if len(fields) != 3 {
+    return nil, fmt.Errorf("rgb needs 3 values, got %d", len(fields))
+  }
+  var result []int
+  for _, field := range fields{
+    num, err := strconv.Atoi(field)
+    if err != nil {
+      return nil, fmt.Errorf("rgb value %q is not a number", field)
+    }
+    if num < 0 || num > 255 {
+      return nil, fmt.Errorf("rgb value %d is out of range 0-255", num)
+    }
+    result = append(result, num)
+  }
+  return result, nil
+}
+
+
+var targetNames = map[ColorTarget]string{
+  TargetFg:        "fg",
+  TargetBg:        "bg",
+  TargetUnderline: "ul",
+}
+
+//String writes the spec back as a tag word. named, palette and css colors keep
+//their name or index, every other color is written as hex
+func (spec ColorSpec) String() string {
+  switch spec.Kind {
+  case KindStyle, KindReset:
+    return spec.Name
+  case KindAuto:
+    return "fg=auto"
+  }
+  prefix := targetNames[spec.Target] + "="
+  switch spec.Kind {
+  case KindNamed:
+    return prefix + spec.Name
+  case KindPalette:
+    return prefix + strconv.Itoa(int(spec.Index))
+  case KindCSS:
+    return prefix + "css:" + spec.Name
+  }
+  if spec.Alpha < 1 {
+    return prefix + spec.Color.String() + fmt.Sprintf("%02x", channel(spec.Alpha))
+  }
+  return prefix + spec.Color.String()
+}
+
+
+//sgr writes the spec as an escape sequence for the level.
+//colors the level can't show are brought down to the nearest one it can
+func (spec ColorSpec) sgr(level ColorLevel, palette *Palette16) string {
+  if level == LevelNone {
+    return ""
+  }
+
+  switch spec.Kind {
+  case KindStyle:
+    return fmt.Sprintf("\033[%sm", StyleMap[spec.Name])
+  case KindReset:
+    return fmt.Sprintf("\033[%sm", ResetMap[spec.Name])
+  case KindAuto:
+    //needs a template to know the background
+    return ""
+  }
+
+  if spec.Target == TargetUnderline {
+    //underline colors only exist as extended colors
+    switch {
+    case level == LevelBasic16:
+      return ""
+    case spec.Kind == KindNamed || spec.Kind == KindPalette:
+      return fmt.Sprintf("\033[58;5;%dm", spec.Index)
+    case level == LevelAnsi256:
+      return fmt.Sprintf("\033[58;5;%dm", spec.Color.Nearest256())
+    }
+    return fmt.Sprintf("\033[58;2;%d;%d;%dm", spec.Color.R, spec.Color.G, spec.Color.B)
+  }
+
+  background := spec.Target == TargetBg
+  switch spec.Kind {
+  case KindNamed:
+    return namedAnsiCode(background, int(spec.Index))
+  case KindPalette:
+    if level == LevelBasic16 {
+      //the first 16 palette entries are the named colors themselves
+      index := int(spec.Index)
+      if index >= 16 {
+        index = rgbTo16(spec.Color.R, spec.Color.G, spec.Color.B, palette)
+      }
+      return namedAnsiCode(background, index)
+    }
+    if background {
+      return fmt.Sprintf("\033[48;5;%dm", spec.Index)
+    }
+    return fmt.Sprintf("\033[38;5;%dm", spec.Index)
+  }
+  return rgbToAnsiCode(background, spec.Color.R, spec.Color.G, spec.Color.B, level, palette)
+}
@@ -0,0 +1,189 @@
+package color
+
+import (
+  "maps"
+  "slices"
+  "strings"
+)
+
+//===========================================
+//  COMPOSITION
+//===========================================
+
+/* compiled templates can be put together without going back to strings:
+
+     header := color.Wrap("bold fg=cyan", color.Parse("[0]"))
+     line := color.Join(" | ", header, color.Parse("[0]"), color.Parse("[0 fg=hash]"))
+     line.Apply("name", "value", "key")
+
+   the placeholders of each piece come after the ones of the pieces before it,
+   and whatever a piece leaves on at its end is reset before the next one starts,
+   back to the styles of a Wrap around the pieces when there is one */
+
+//settings returns the toggle the template was parsed with, or a detected one
+//for templates made without Parse
+func (temp CompiledTemplate) settings() *ColorToggle {
+  if temp.toggle == nil {
+    return NewColorToggle()
+  }
+  return temp.toggle
+}
+
+//slotCount is how many arguments the template takes: its highest placeholder plus one
+func (temp CompiledTemplate) slotCount() int {
+  slots := temp.Slots()
+  if len(slots) == 0 {
+    return 0
+  }
+  return slots[len(slots)-1] + 1
+}
+
+//offset moves every placeholder of the template up by n
+func (temp CompiledTemplate) offset(n int) CompiledTemplate {
+  if n == 0 {
+    return temp
+  }
+  return temp.bind(nil, nil, func(index int) int { return index + n })
+}
+
+//closingParts resets what the tags of parts leave on at their end, one target or
+//style at a time so styles from around the template stay. tags inside blocks
+//count too, a block like [upper] leaves them on after it
+func closingParts(parts []TempPart, toggle *ColorToggle) []TempPart {
+  ctx := colorContext{active: map[string]string{}}
+  var track func(parts []TempPart)
+  track = func(parts []TempPart) {
+    for _, part := range parts{
+      switch {
+      case part.block != nil:
+        track(part.block.inner.Parts)
+      case part.Tag != "":
+        ctx.track(part.Tag)
+      }
+    }
+  }
+  track(parts)
+
+  var closing []TempPart
+  for _, key := range slices.Sorted(maps.Keys(ctx.active)){
+    tag := key + "=reset"
+    if _, exists := ResetMap[tag]; !exists && key != "link" {
+      continue
+    }
+    closing = append(closing, TempPart{Text: tagCode(tag, toggle.Level, toggle.Palette), Index: -1, Tag: tag, closes: ctx.active[key]})
+  }
+  return closing
+}
+
+//reopen points the resets closingParts put in parts at outside, the styles a
+//wrapper has on around them: a reset of a style outside has becomes that style
+//again, and outside styles sharing the code of a reset (bold and dim) are put back after it
+func reopen(parts []TempPart, outside colorContext, toggle *ColorToggle) []TempPart {
+  if len(outside.active) == 0 {
+    return parts
+  }
+  result := make([]TempPart, 0, len(parts))
+  for _, part := range parts{
+    if part.closes == "" || !strings.HasSuffix(part.Tag, "=reset") {
+      result = append(result, part)
+      continue
+    }
+    key, _, _ := strings.Cut(part.closes, "=")
+    if previous, exists := outside.active[key]; exists {
+      //when the piece left on what outside has there is nothing to close
+      if previous != part.closes {
+        part.Tag = previous
+        part.Text = tagCode(previous, toggle.Level, toggle.Palette)
+        result = append(result, part)
+      }
+      continue
+    }
+    result = append(result, part)
+    for _, tag := range sharedResets(part.Tag, outside){
+      result = append(result, TempPart{Text: tagCode(tag, toggle.Level, toggle.Palette), Index: -1, Tag: tag})
+    }
+  }
+  return result
+}
+
+//Text makes a template of plain text, brackets and all. like Parse, it takes the
+//detected toggle, which Wrap uses for its style
+func Text(s string) CompiledTemplate {
+  temp := CompiledTemplate{toggle: NewColorToggle()}
+  if s != ""{
+    temp.Parts = []TempPart{{Text: s, Index: -1}}
+    temp.TotalLength = len(s)
+  }
+  return temp
+}
+
+//Concat puts templates one after another. the placeholders of each come after
+//the ones of the templates before it, so Concat(Parse("[0]"), Parse("[0]"))
+//takes two arguments
+func Concat(templates ...CompiledTemplate) CompiledTemplate {
+  var (
+    result CompiledTemplate
+    slots  = 0
+  )
+  for i, temp := range templates{
+    shifted := temp.offset(slots)
+    slots += temp.slotCount()
+    //the result keeps the settings of the first template with the highest level
+    if temp.toggle != nil && (result.toggle == nil || temp.toggle.Level > result.toggle.Level) {
+      result.toggle = temp.toggle
+    }
+
+    result.Parts = append(result.Parts, shifted.Parts...)
+    if i < len(templates)-1 {
+      result.Parts = append(result.Parts, closingParts(temp.Parts, temp.settings())...)
+    }
+  }
+  for _, part := range result.Parts{
+    result.TotalLength += len(part.Text)
+  }
+  return result
+}
+
+//Join is Concat with sep as plain text between the templates
+func Join(sep string, templates ...CompiledTemplate) CompiledTemplate {
+  pieces := make([]CompiledTemplate, 0, 2*len(templates))
+  for i, temp := range templates{
+    if i > 0 && sep != ""{
+      pieces = append(pieces, Text(sep))
+    }
+    pieces = append(pieces, temp)
+  }
+  return Concat(pieces...)
+}
+
+//Wrap styles a whole template with tag words like "bold fg=cyan", and resets what
+//they and the template leave on at the end. the words are parsed with the toggle
+//of the template, its level, background, CVDMode and maps included. a word that
+//isn't a style stays text like in Parse
+func Wrap(style string, temp CompiledTemplate) CompiledTemplate {
+  toggle := temp.settings()
+  open := toggle.Parse("[" + strings.TrimSpace(style) + "]")
+  result := CompiledTemplate{toggle: toggle}
+  outside := colorContext{active: map[string]string{}}
+  for _, part := range open.Parts{
+    if part.Tag != "" {
+      outside.track(part.Tag)
+    }
+  }
+  result.Parts = append(result.Parts, open.Parts...)
+  //the pieces of temp close back to the style of the wrapper, not to the defaults
+  result.Parts = append(result.Parts, reopen(temp.Parts, outside, toggle)...)
+  result.Parts = append(result.Parts, closingParts(result.Parts, toggle)...)
+  for _, part := range result.Parts{
+    result.TotalLength += len(part.Text)
+  }
+  return result
+}
+
+//Repeat is Concat of n copies of the template, each copy with placeholders of its own
+func Repeat(temp CompiledTemplate, n int) CompiledTemplate {
+  if n <= 0 {
+    return CompiledTemplate{toggle: temp.toggle}
+  }
+  return Concat(slices.Repeat([]CompiledTemplate{temp}, n)...)
+}
@@ -0,0 +1,107 @@
+package color
+
+import (
+  "fmt"
+  "math"
+  "os"
+)
+
+//===========================================
+//  CONTRAST
+//===========================================
+
+//Luminance is the WCAG relative luminance, 0 for black and 1 for white
+func (c Color) Luminance() float64 {
+  return 0.2126*linearTable[c.R] + 0.7152*linearTable[c.G] + 0.0722*linearTable[c.B]
+}
+
+//Contrast is the WCAG contrast ratio of two colors, from 1 (none) to 21 (black on white).
+//text needs 4.5 for AA and 7 for AAA, large text 3
+func Contrast(a, b Color) float64 {
+  la, lb := a.Luminance(), b.Luminance()
+  return (math.Max(la, lb) + 0.05) / (math.Min(la, lb) + 0.05)
+}
+
+//readableOn picks black or white, whichever has more contrast on bg
+func readableOn(bg Color) Color {
+  black, white := RGB(0, 0, 0), RGB(255, 255, 255)
+  if Contrast(black, bg) >= Contrast(white, bg) {
+    return black
+  }
+  return white
+}
+
+
+//contrastState is the fg and bg checkContrast has seen set so far
+type contrastState struct {
+  fg, bg       Color
+  fgSet, bgSet bool
+}
+
+func (state *contrastState) apply(tag string) {
+  spec, err := ParseColorSpec(tag)
+  if err != nil {
+    return
+  }
+  switch {
+  case spec.Name == "reset":
+    state.fgSet, state.bgSet = false, false
+  case spec.Target == TargetFg:
+    state.fg, state.fgSet = spec.Color, spec.Kind != KindReset
+  case spec.Target == TargetBg:
+    state.bg, state.bgSet = spec.Color, spec.Kind != KindReset
+  }
+}
+
+//checkContrast walks the parts of a parsed template and warns about every text that sits
+//on an fg/bg pair (both set in the template) below toggle.MinContrast. the text inside
+//blocks and the fixed colors of styled placeholders are checked too
+func (toggle *ColorToggle) checkContrast(input string, parts []TempPart) {
+  var (
+    state  contrastState
+    warned = map[[2]Color]bool{}
+    walk   func(parts []TempPart)
+  )
+  check := func() {
+    if !state.fgSet || !state.bgSet || warned[[2]Color{state.fg, state.bg}] {
+      return
+    }
+    if ratio := Contrast(state.fg, state.bg); ratio < toggle.MinContrast {
+      warned[[2]Color{state.fg, state.bg}] = true
+      toggle.warn(fmt.Sprintf("color: low contrast %.2f:1 (want %.1f:1) for fg %s on bg %s in %q", ratio, toggle.MinContrast, state.fg, state.bg, input))
+    }
+  }
+  walk = func(parts []TempPart) {
+    for _, part := range parts{
+      switch {
+      case part.Tag != "":
+        state.apply(part.Tag)
+      case part.block != nil && part.block.name == "placeholder":
+        //the styles of the value only last for the value
+        outside := state
+        for _, tag := range part.block.fixed{
+          state.apply(tag)
+        }
+        check()
+        state = outside
+      case part.block != nil:
+        outside := state
+        walk(part.block.inner.Parts)
+        if part.block.tag.setsFg {
+          state.fg, state.fgSet = outside.fg, outside.fgSet
+        }
+      case part.Text != "" || part.Index >= 0:
+        check()
+      }
+    }
+  }
+  walk(parts)
+}
+
+func (toggle *ColorToggle) warn(message string) {
+  if toggle.Warn != nil {
+    toggle.Warn(message)
+    return
+  }
+  fmt.Fprintln(os.Stderr, message)
+}
@@ -0,0 +1,182 @@
+package color
+
+//===========================================
+//  COLOR VISION DEFICIENCY
+//===========================================
+
+//CVD is a kind of color vision deficiency
+type CVD int
+
+const (
+  CVDNone      CVD = iota
+  Protanopia       //no red cones, red and green look alike and red looks dark
+  Deuteranopia     //no green cones, red and green look alike
+  Tritanopia       //no blue cones, blue and green, yellow and white look alike
+)
+
+func (mode CVD) String() string {
+  switch mode {
+  case Protanopia:
+    return "protanopia"
+  case Deuteranopia:
+    return "deuteranopia"
+  case Tritanopia:
+    return "tritanopia"
+  }
+  return "none"
+}
+
+//simulation matrices on linear rgb, from Machado, Oliveira and Fernandes (2009) at full severity
+var cvdMatrices = map[CVD][3][3]float64{
+  Protanopia: {
+    {0.152286, 1.052583, -0.204868},
+    {0.114503, 0.786281, 0.099216},
+    {-0.003882, -0.048116, 1.051998},
+  },
+  Deuteranopia: {
+    {0.367322, 0.860646, -0.227968},
+    {0.280085, 0.672501, 0.047413},
+    {-0.011820, 0.042940, 0.968881},
+  },
+  Tritanopia: {
+    {1.255528, -0.076749, -0.178779},
+    {-0.078411, 0.930809, 0.148220},
+    {0.004733, 0.691367, 0.303900},
+  },
+}
+
+//daltonize matrices: where the color information the viewer loses is moved to
+var cvdShifts = map[CVD][3][3]float64{
+  Protanopia:   {{0, 0, 0}, {0.7, 1, 0}, {0.7, 0, 1}},
+  Deuteranopia: {{0, 0, 0}, {0.7, 1, 0}, {0.7, 0, 1}},
+  Tritanopia:   {{1, 0, 0.7}, {0, 1, 0.7}, {0, 0, 0}},
+}
+
+//cvdSafePalette replaces the 16 named colors above 16 color output. the hues are
+//the Okabe-Ito set, which stays apart for all three deficiencies, and the light
+//colors are the same hues a step lighter
+var cvdSafePalette = Palette16{
+  RGB(0x00, 0x00, 0x00),
+  RGB(0xd5, 0x5e, 0x00), //vermillion
+  RGB(0x00, 0x9e, 0x73), //bluish green
+  RGB(0xf0, 0xe4, 0x42), //yellow
+  RGB(0x00, 0x72, 0xb2), //blue
+  RGB(0xcc, 0x79, 0xa7), //reddish purple
+  RGB(0x56, 0xb4, 0xe9), //sky blue
+  RGB(0xe5, 0xe5, 0xe5),
+  RGB(0x7f, 0x7f, 0x7f),
+  RGB(0xf8, 0x7e, 0x36),
+  RGB(0x3f, 0xbe, 0x91),
+  RGB(0xf7, 0xef, 0x8a),
+  RGB(0x34, 0x91, 0xd3),
+  RGB(0xee, 0x98, 0xc7),
+  RGB(0x8a, 0xd3, 0xff),
+  RGB(0xff, 0xff, 0xff),
+}
+
+//cvdSwaps moves named colors at 16 color output, where the terminal theme picks the
+//rgb. each color that would be confused goes one step along a cycle, so every
+//table is a permutation and no two colors end up the same
+var cvdSwaps = map[CVD]map[int]int{
+  Protanopia:   {2: 4, 4: 6, 6: 2, 10: 12, 12: 14, 14: 10}, //green to blue, blue to cyan, cyan to green
+  Deuteranopia: {2: 4, 4: 6, 6: 2, 10: 12, 12: 14, 14: 10},
+  Tritanopia:   {6: 4, 4: 5, 5: 6, 14: 12, 12: 13, 13: 14}, //cyan to blue, blue to magenta, magenta to cyan
+}
+
+func applyMatrix(m [3][3]float64, v [3]float64) [3]float64 {
+  var out [3]float64
+  for i := range out{
+    out[i] = m[i][0]*v[0] + m[i][1]*v[1] + m[i][2]*v[2]
+  }
+  return out
+}
+
+//Simulate returns the color as a viewer with the deficiency sees it
+func (c Color) Simulate(mode CVD) Color {
+  m, exists := cvdMatrices[mode]
+  if !exists {
+    return c
+  }
+  out := applyMatrix(m, [3]float64{linearTable[c.R], linearTable[c.G], linearTable[c.B]})
+  return Color{fromLinear(out[0]), fromLinear(out[1]), fromLinear(out[2])}
+}
+
+//Daltonize shifts the part of the color the viewer can't see into channels they can,
+//so colors that would look alike come apart
+func (c Color) Daltonize(mode CVD) Color {
+  shift, exists := cvdShifts[mode]
+  if !exists {
+    return c
+  }
+  seen := c.Simulate(mode)
+  lost := [3]float64{
+    float64(c.R) - float64(seen.R),
+    float64(c.G) - float64(seen.G),
+    float64(c.B) - float64(seen.B),
+  }
+  moved := applyMatrix(shift, lost)
+  return Color{
+    channel((float64(c.R) + moved[0]) / 255),
+    channel((float64(c.G) + moved[1]) / 255),
+    channel((float64(c.B) + moved[2]) / 255),
+  }
+}
+
+//remap swaps the color of a spec for one the viewer can tell apart: named colors
+//go through cvdSwaps or cvdSafePalette, every other color is daltonized
+func (mode CVD) remap(spec ColorSpec, level ColorLevel) ColorSpec {
+  if mode == CVDNone || spec.Target == TargetNone || spec.Kind == KindReset || spec.Kind == KindAuto {
+    return spec
+  }
+  named := spec.Kind == KindNamed || (spec.Kind == KindPalette && spec.Index < 16)
+  switch {
+  case named && level <= LevelBasic16:
+    if to, exists := cvdSwaps[mode][int(spec.Index)]; exists {
+      spec.Kind, spec.Index, spec.Name, spec.Color = KindNamed, uint8(to), namedColors[to], XtermPalette16[to]
+    }
+  case named:
+    spec.Kind, spec.Color = KindHex, cvdSafePalette[spec.Index]
+  default:
+    spec.Kind, spec.Color = KindHex, spec.Color.Daltonize(mode)
+  }
+  return spec
+}
+
+//remapTag is remap for a tag word, words that aren't colors come back as they are
+func (mode CVD) remapTag(tag string, level ColorLevel) string {
+  spec, err := ParseColorSpec(tag)
+  if err != nil {
+    return tag
+  }
+  if remapped := mode.remap(spec, level); remapped != spec {
+    return remapped.String()
+  }
+  return tag
+}
+
+
+//CVDRenderer previews output as a viewer with the deficiency sees it. every
+//color is simulated and written as hex before the spans go to Next
+type CVDRenderer struct {
+  Mode CVD
+  //the renderer that writes the output, ANSIRenderer when nil
+  Next Renderer
+}
+
+func (r CVDRenderer) Render(spans []Span) string {
+  next := r.Next
+  if next == nil {
+    next = ANSIRenderer{}
+  }
+  simulated := make([]Span, len(spans))
+  for i, span := range spans{
+    simulated[i] = span
+    spec, err := ParseColorSpec(span.Tag)
+    if span.Tag == "" || err != nil || spec.Target == TargetNone || spec.Kind == KindReset || spec.Kind == KindAuto {
+      continue
+    }
+    spec.Kind, spec.Color = KindHex, spec.Color.Simulate(r.Mode)
+    simulated[i].Tag = spec.String()
+  }
+  return next.Render(simulated)
+}
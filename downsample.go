@@ -0,0 +1,95 @@
+package color
+
+import (
+  "fmt"
+  "os"
+  "strings"
+)
+
+//===========================================
+//  COLOR LEVELS AND DOWNSAMPLING
+//===========================================
+
+//ColorLevel is how many colors the terminal can show
+type ColorLevel int
+
+const (
+  LevelNone ColorLevel = iota //no escape codes at all
+  LevelBasic16                //the 16 named colors and styles
+  LevelAnsi256                //the xterm 256 palette
+  LevelTrueColor              //24 bit rgb
+)
+
+func (level ColorLevel) String() string {
+  switch level {
+  case LevelNone:
+    return "none"
+  case LevelBasic16:
+    return "16"
+  case LevelAnsi256:
+    return "256"
+  case LevelTrueColor:
+    return "truecolor"
+  }
+  return fmt.Sprintf("ColorLevel(%d)", int(level))
+}
+
+//detectColorLevel reads COLORTERM and TERM. it doesn't look at NO_COLOR or the tty,
+//the toggle does that
+func detectColorLevel() ColorLevel {
+  term := os.Getenv("TERM")
+  if term == "dumb" {
+    return LevelNone
+  }
+  if supportsTrueColor() {
+    return LevelTrueColor
+  }
+  if strings.HasSuffix(term, "256color") {
+    return LevelAnsi256
+  }
+  return LevelBasic16
+}
+
+//rgbTo256 returns the nearest index in the xterm 256 palette, leaving out
+//the first 16 since terminals theme those
+func rgbTo256(r, g, b uint8) int {
+  return int(Color{r, g, b}.Nearest256())
+}
+
+//rgbTo16 returns the nearest of the 16 named colors as palette shows them,
+//nil is XtermPalette16
+func rgbTo16(r, g, b uint8, palette *Palette16) int {
+  if palette == nil {
+    palette = &XtermPalette16
+  }
+  return int(Color{r, g, b}.Nearest16(*palette))
+}
+
+//rgbToAnsiCode writes an rgb color as the best escape sequence for the level,
+//matching it against palette at LevelBasic16
+func rgbToAnsiCode(background bool, r, g, b uint8, level ColorLevel, palette *Palette16) string {
+  switch level {
+  case LevelTrueColor:
+    if background {
+      return fmt.Sprintf("\033[48;2;%d;%d;%dm", r, g, b)
+    }
+    return fmt.Sprintf("\033[38;2;%d;%d;%dm", r, g, b)
+  case LevelAnsi256:
+    if background {
+      return fmt.Sprintf("\033[48;5;%dm", rgbTo256(r, g, b))
+    }
+    return fmt.Sprintf("\033[38;5;%dm", rgbTo256(r, g, b))
+  case LevelBasic16:
+    return namedAnsiCode(background, rgbTo16(r, g, b, palette))
+  }
+  return ""
+}
+
+//namedAnsiCode writes one of the 16 named colors (0-15) as an escape sequence
+func namedAnsiCode(background bool, index int) string {
+  prefix := "fg="
+  if background {
+    prefix = "bg="
+  }
+  return fmt.Sprintf("\033[%sm", ColorMap[prefix+namedColors[index]])
+}
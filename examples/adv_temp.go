@@ -7,8 +7,12 @@ import (
 )
 
 func main() {
-    // Status indicator with conditional colors
-    statusTemplate := color.Parse("[0] [1][reset]")
+    // Status indicator with conditional colors, the style is picked by the status value
+    templates := color.NewTemplateSet(nil).Map("status", map[string]string{
+        "Online":  "fg=green bold",
+        "Offline": "fg=red bold",
+    }, "fg=yellow")
+    statusTemplate := templates.Parse("[0] [1 map=status]")
     
     items := []struct{
         name string
@@ -20,18 +24,7 @@ func main() {
     }
     
     for _, item := range items {
-        var statusColor string
-        switch item.status {
-        case "Online":
-            statusColor = "[fg=green bold]"
-        case "Offline":
-            statusColor = "[fg=red bold]"
-        default:
-            statusColor = "[fg=yellow]"
-        }
-        
-        statusColored := color.Parse(statusColor + item.status).Apply()
-        fmt.Println(statusTemplate.Apply(item.name + ":", statusColored))
+        fmt.Println(statusTemplate.Apply(item.name + ":", item.status))
     }
     
     // Progress bar template
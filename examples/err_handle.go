@@ -20,8 +20,8 @@ func main() {
         fmt.Println(validationTemplate.Apply(field, message))
     }
     
-    // Template with conditional formatting
-    scoreTemplate := color.Parse("[0]: [1]")
+    // Template with conditional formatting, the color is picked from the score when applied
+    scoreTemplate := color.Parse("[0]: [1 scale=90:green+bold,70:yellow,0:red]")
     
     scores := []struct{
         name string
@@ -34,17 +34,6 @@ func main() {
     }
     
     for _, s := range scores {
-        var scoreColor string
-        switch {
-        case s.score >= 90:
-            scoreColor = "[fg=green bold]"
-        case s.score >= 70:
-            scoreColor = "[fg=yellow]"
-        default:
-            scoreColor = "[fg=red]"
-        }
-        
-        coloredScore := color.Parse(scoreColor + fmt.Sprint(s.score)+ "[reset]").Apply()
-        fmt.Println(scoreTemplate.Apply(s.name, coloredScore))
+        fmt.Println(scoreTemplate.Apply(s.name, s.score))
     }
 }
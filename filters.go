@@ -0,0 +1,305 @@
+package color
+
+import (
+  "fmt"
+  "math"
+  "strconv"
+  "strings"
+  "sync"
+  "time"
+  "unicode"
+)
+
+//===========================================
+//  FILTERS
+//===========================================
+
+/* filters change a placeholder's value before it is written: [0|upper], [1|trunc:30],
+   [2|bytes|pad:10]. they run left to right, each one gets what the one before returned.
+   an argument goes after a colon, and can be quoted: [4|default:"not set"].
+   filter names are looked up when the template is parsed, an unknown one leaves the
+   tag as text */
+
+//Filter changes a placeholder value. arg is what came after the colon, "" when nothing did.
+//value is nil for a placeholder without an argument
+type Filter func(value any, arg string) any
+
+type filterDef struct {
+  filter Filter
+  //checks the arg at parse time, built in filters only
+  validate func(arg string) error
+}
+
+var (
+  filtersMu sync.RWMutex
+  filters   = map[string]filterDef{
+    "upper":   {filter: stringFilter(strings.ToUpper), validate: noArg},
+    "lower":   {filter: stringFilter(strings.ToLower), validate: noArg},
+    "title":   {filter: stringFilter(titleCase), validate: noArg},
+    "trim":    {filter: stringFilter(strings.TrimSpace), validate: noArg},
+    "trunc":   {filter: truncFilter, validate: widthArg},
+    "ltrunc":  {filter: leftTruncFilter, validate: widthArg},
+    "pad":     {filter: padFilter, validate: widthArg},
+    "lpad":    {filter: leftPadFilter, validate: widthArg},
+    "bytes":   {filter: bytesFilter, validate: noArg},
+    "duration": {filter: durationFilter, validate: noArg},
+    "default": {filter: defaultFilter},
+  }
+)
+
+//RegisterFilter adds a filter for templates parsed after it, or replaces the one
+//with that name. names can't hold spaces, '|', ':' or brackets
+func RegisterFilter(name string, filter Filter) {
+  if name == "" || strings.ContainsAny(name, " |:[]\"") || filter == nil {
+    panic(fmt.Sprintf("color: RegisterFilter %q: bad name or nil filter", name))
+  }
+  filtersMu.Lock()
+  defer filtersMu.Unlock()
+  filters[name] = filterDef{filter: filter}
+}
+
+//boundFilter is a filter found at parse time with its arg
+type boundFilter struct {
+  filter Filter
+  arg    string
+}
+
+//parseFilters reads the filters after the index: "upper", "trunc:30", `default:"n/a"`
+func parseFilters(chain []string) ([]boundFilter, error) {
+  filtersMu.RLock()
+  defer filtersMu.RUnlock()
+
+  bound := make([]boundFilter, 0, len(chain))
+  for _, call := range chain{
+    name, arg, _ := strings.Cut(call, ":")
+    if unquoted, err := strconv.Unquote(arg); err == nil {
+      arg = unquoted
+    }
+    def, exists := filters[name]
+    if !exists {
+      return nil, fmt.Errorf("color: unknown filter %q", name)
+    }
+    if def.validate != nil {
+      if err := def.validate(arg); err != nil {
+        return nil, fmt.Errorf("color: filter %q: %v", name, err)
+      }
+    }
+    bound = append(bound, boundFilter{def.filter, arg})
+  }
+  return bound, nil
+}
+
+//splitFilters splits "0|trunc:30|default:\"a|b\"" at the pipes outside quotes
+func splitFilters(word string) []string {
+  var (
+    fields []string
+    quoted = false
+    start  = 0
+  )
+  for i, r := range word{
+    switch {
+    case r == '"' && (i == 0 || word[i-1] != '\\'):
+      quoted = !quoted
+    case r == '|' && !quoted:
+      fields = append(fields, word[start:i])
+      start = i + 1
+    }
+  }
+  return append(fields, word[start:])
+}
+
+//value returns the text of a placeholder part, run through its filters.
+//ok is false when there is no argument and no filter to make up for it
+func (part TempPart) value(args []any) (string, bool) {
+  var value any
+  if part.Index < len(args) {
+    value = args[part.Index]
+  } else if len(part.filters) == 0 {
+    return "", false
+  }
+  for _, bound := range part.filters{
+    value = bound.filter(value, bound.arg)
+  }
+  if value == nil {
+    return "", true
+  }
+  return fmt.Sprint(value), true
+}
+
+
+//======================================
+// BUILT IN FILTERS
+//======================================
+
+func widthArg(arg string) error {
+  if width, err := strconv.Atoi(arg); err != nil || width < 1 {
+    return fmt.Errorf("%q is not a width", arg)
+  }
+  return nil
+}
+
+func text(value any) string {
+  if value == nil {
+    return ""
+  }
+  return fmt.Sprint(value)
+}
+
+func stringFilter(transform func(string) string) Filter {
+  return func(value any, arg string) any {
+    return transform(text(value))
+  }
+}
+
+func titleCase(s string) string {
+  runes := []rune(s)
+  for i, r := range runes{
+    if i == 0 || unicode.IsSpace(runes[i-1]) {
+      runes[i] = unicode.ToUpper(r)
+    }
+  }
+  return string(runes)
+}
+
+//truncFilter cuts the text to arg cells, ending it with "…"
+func truncFilter(value any, arg string) any {
+  width, _ := strconv.Atoi(arg)
+  s := text(value)
+  if textWidth(s) <= width {
+    return s
+  }
+  var result strings.Builder
+  used := 0
+  for _, g := range graphemes(s){
+    if used+textWidth(g) > width-1 {
+      break
+    }
+    result.WriteString(g)
+    used += textWidth(g)
+  }
+  return result.String() + "…"
+}
+
+//leftTruncFilter keeps the last arg cells, for paths where the end matters most
+func leftTruncFilter(value any, arg string) any {
+  width, _ := strconv.Atoi(arg)
+  s := text(value)
+  if textWidth(s) <= width {
+    return s
+  }
+  parts := graphemes(s)
+  used, start := 0, len(parts)
+  for start > 0 && used+textWidth(parts[start-1]) <= width-1 {
+    start--
+    used += textWidth(parts[start])
+  }
+  return "…" + strings.Join(parts[start:], "")
+}
+
+//padFilter pads with spaces on the right to arg cells
+func padFilter(value any, arg string) any {
+  width, _ := strconv.Atoi(arg)
+  s := text(value)
+  return s + strings.Repeat(" ", max(0, width-textWidth(s)))
+}
+
+//leftPadFilter pads on the left, so numbers line up
+func leftPadFilter(value any, arg string) any {
+  width, _ := strconv.Atoi(arg)
+  s := text(value)
+  return strings.Repeat(" ", max(0, width-textWidth(s))) + s
+}
+
+//number reads ints, uints, floats and numeric strings
+func number(value any) (float64, bool) {
+  switch v := value.(type) {
+  case int:
+    return float64(v), true
+  case int8:
+    return float64(v), true
+  case int16:
+    return float64(v), true
+  case int32:
+    return float64(v), true
+  case int64:
+    return float64(v), true
+  case uint:
+    return float64(v), true
+  case uint8:
+    return float64(v), true
+  case uint16:
+    return float64(v), true
+  case uint32:
+    return float64(v), true
+  case uint64:
+    return float64(v), true
+  case float32:
+    return float64(v), true
+  case float64:
+    return v, true
+  case string:
+    f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+    return f, err == nil
+  }
+  return 0, false
+}
+
+//bytesFilter writes a byte count in binary units: 1536 is "1.5 KiB"
+func bytesFilter(value any, arg string) any {
+  n, ok := number(value)
+  if !ok {
+    return value
+  }
+  units := []string{"B", "KiB", "MiB", "GiB", "TiB", "PiB", "EiB"}
+  unit := 0
+  for math.Abs(n) >= 1024 && unit < len(units)-1 {
+    n /= 1024
+    unit++
+  }
+  if unit == 0 {
+    return fmt.Sprintf("%d B", int64(n))
+  }
+  //rounding can reach the next unit: 1023.96 KiB is 1 MiB
+  rounded := math.Round(n*10) / 10
+  if math.Abs(rounded) >= 1024 && unit < len(units)-1 {
+    rounded = math.Round(n/1024*10) / 10
+    unit++
+  }
+  return strconv.FormatFloat(rounded, 'f', -1, 64) + " " + units[unit]
+}
+
+//durationFilter rounds a time.Duration, or a number of seconds, to what a reader
+//cares about: 1m30s, 2.35s, 120ms
+func durationFilter(value any, arg string) any {
+  var d time.Duration
+  switch v := value.(type) {
+  case time.Duration:
+    d = v
+  default:
+    seconds, ok := number(value)
+    if !ok {
+      return value
+    }
+    d = time.Duration(seconds * float64(time.Second))
+  }
+  abs := d.Abs()
+  switch {
+  case abs >= time.Minute:
+    d = d.Round(time.Second)
+  case abs >= time.Second:
+    d = d.Round(10 * time.Millisecond)
+  case abs >= time.Millisecond:
+    d = d.Round(time.Millisecond)
+  default:
+    d = d.Round(time.Microsecond)
+  }
+  return d.String()
+}
+
+//defaultFilter replaces a missing or empty value with arg
+func defaultFilter(value any, arg string) any {
+  if text(value) == "" {
+    return arg
+  }
+  return value
+}
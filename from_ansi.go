@@ -0,0 +1,150 @@
+package color
+
+import (
+  "errors"
+  "strconv"
+  "strings"
+)
+
+//===========================================
+//  ANSI TO MARKUP
+//===========================================
+
+//FromANSI turns text with escape sequences into template markup like [fg=red bold].
+//codes that don't change anything are dropped and the rest are collapsed into one
+//tag per text run. sequences that have no markup (cursor movement, hyperlinks, unknown SGR codes)
+//and text that would be read as markup are kept in [raw]...[/raw] blocks
+func FromANSI(input string) (string, error) {
+  var (
+    parser  ANSIParser
+    out     strings.Builder
+    current styleState
+    written styleState
+    //raw text waiting to be closed, so neighbouring raw pieces share one block
+    rawText strings.Builder
+  )
+
+  closeRaw := func() {
+    if rawText.Len() > 0 {
+      //a [/raw] in the text would end the block, so it is split over two blocks
+      text := strings.ReplaceAll(rawText.String(), "[/raw]", "[/[/raw][raw]raw]")
+      out.WriteString("[raw]" + text + "[/raw]")
+      rawText.Reset()
+    }
+  }
+  flush := func() {
+    if tags := stateDiff(written, current); len(tags) > 0 {
+      closeRaw()
+      out.WriteString("[" + strings.Join(tags, " ") + "]")
+    }
+    written = current
+  }
+  raw := func(text string) {
+    rawText.WriteString(text)
+  }
+
+  parser.Unsupported = func(seq string) {
+    flush()
+    raw(seq)
+  }
+  emit := func(span Span) {
+    switch {
+    case strings.HasPrefix(span.Tag, "link="):
+      //no markup for hyperlinks yet
+      flush()
+      if span.Tag == "link=reset" {
+        raw("\033]8;;\033\\")
+      } else {
+        raw("\033]8;;" + span.Tag[5:] + "\033\\")
+      }
+    case span.Tag != "":
+      current.apply(span.Tag)
+    case span.Text != "":
+      flush()
+      if strings.Contains(span.Text, "[") {
+        raw(span.Text)
+      } else {
+        closeRaw()
+        out.WriteString(span.Text)
+      }
+    }
+  }
+
+  parser.Feed([]byte(input), emit)
+  if len(parser.pending) > 0 && parser.pending[0] == 0x1b {
+    return "", errors.New("color: unterminated escape sequence at end of input")
+  }
+  parser.Flush(emit)
+  flush()
+  closeRaw()
+  return out.String(), nil
+}
+
+
+//tag writes a color back as the markup it would be parsed from
+func (c stateColor) tag(prefix string) string {
+  switch {
+  case !c.set:
+    return prefix + "reset"
+  case c.name != "":
+    return prefix + c.name
+  case c.index >= 0:
+    return prefix + strconv.Itoa(c.index)
+  }
+  return prefix + c.hex()
+}
+
+//stateDiff returns the fewest tags that take the terminal from one state to another
+func stateDiff(from, to styleState) []string {
+  if from == to {
+    return nil
+  }
+  if to == (styleState{link: to.link}) {
+    return []string{"reset"}
+  }
+
+  var tags []string
+  //bold and dim share one reset
+  if (from.bold && !to.bold) || (from.dim && !to.dim) {
+    tags = append(tags, "bold=reset")
+    from.bold, from.dim = false, false
+  }
+  if to.bold && !from.bold {
+    tags = append(tags, "bold")
+  }
+  if to.dim && !from.dim {
+    tags = append(tags, "dim")
+  }
+  if from.italic != to.italic {
+    tags = append(tags, pick(to.italic, "italic", "italic=reset"))
+  }
+  if from.underline != to.underline {
+    tags = append(tags, [3]string{"underline=reset", "underline=single", "underline=double"}[to.underline])
+  }
+  if from.blink != to.blink {
+    tags = append(tags, pick(to.blink, "blink=slow", "blink=reset"))
+  }
+  if from.reverse != to.reverse {
+    tags = append(tags, pick(to.reverse, "reverse", "reverse=reset"))
+  }
+  if from.hidden != to.hidden {
+    tags = append(tags, pick(to.hidden, "hidden", "hidden=reset"))
+  }
+  if from.strike != to.strike {
+    tags = append(tags, pick(to.strike, "strike", "strike=reset"))
+  }
+  if from.fg != to.fg {
+    tags = append(tags, to.fg.tag("fg="))
+  }
+  if from.bg != to.bg {
+    tags = append(tags, to.bg.tag("bg="))
+  }
+  return tags
+}
+
+func pick(cond bool, yes, no string) string {
+  if cond {
+    return yes
+  }
+  return no
+}
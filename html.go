@@ -0,0 +1,289 @@
+package color
+
+import (
+  "fmt"
+  "html"
+  "io"
+  "net/url"
+  "slices"
+  "strings"
+)
+
+//===========================================
+//  HTML RENDERER
+//===========================================
+
+//HTMLRenderer renders spans as <span> elements for ci reports and dashboards.
+//with Classes on, named and 256 palette colors use classes like color-fg-red and
+//styles use classes like color-bold (see HTMLStylesheet). hex and rgb colors are
+//always written inline as color:#rrggbb, whatever the terminal supports
+type HTMLRenderer struct {
+  Classes bool
+  //schemes link= tags may point to, DefaultLinkSchemes when nil. text with a
+  //link to any other scheme (javascript:, data:...) is written without the <a>
+  LinkSchemes []string
+}
+
+//DefaultLinkSchemes are the link schemes html output allows unless told otherwise
+var DefaultLinkSchemes = []string{"http", "https", "mailto", "file"}
+
+//safeLink returns target when its scheme is allowed, or "" for no link
+func (r HTMLRenderer) safeLink(target string) string {
+  if target == ""{
+    return ""
+  }
+  schemes := r.LinkSchemes
+  if schemes == nil {
+    schemes = DefaultLinkSchemes
+  }
+  parsed, err := url.Parse(target)
+  if err != nil || !slices.Contains(schemes, strings.ToLower(parsed.Scheme)) {
+    return ""
+  }
+  return target
+}
+
+func (r HTMLRenderer) Render(spans []Span) string {
+  var result strings.Builder
+  writer := htmlWriter{renderer: r, changed: true}
+  for _, span := range spans{
+    writer.write(&result, span)
+  }
+  writer.close(&result)
+  return result.String()
+}
+
+
+//htmlWriter keeps the open <span> and <a> between spans so html can be streamed
+type htmlWriter struct {
+  renderer HTMLRenderer
+  state    styleState
+  spanOpen bool
+  link     string
+  changed  bool
+}
+
+func (writer *htmlWriter) write(out *strings.Builder, span Span) {
+  if span.Tag != ""{
+    writer.state.apply(span.Tag)
+    writer.changed = true
+    return
+  }
+  if span.Text == ""{
+    return
+  }
+  if writer.changed {
+    if writer.spanOpen {
+      out.WriteString("</span>")
+      writer.spanOpen = false
+    }
+    if link := writer.renderer.safeLink(writer.state.link); writer.link != link {
+      if writer.link != ""{
+        out.WriteString("</a>")
+      }
+      if link != ""{
+        out.WriteString(`<a href="` + html.EscapeString(link) + `">`)
+      }
+      writer.link = link
+    }
+    if attr := writer.renderer.attributes(writer.state); attr != ""{
+      out.WriteString("<span " + attr + ">")
+      writer.spanOpen = true
+    }
+    writer.changed = false
+  }
+  out.WriteString(html.EscapeString(span.Text))
+}
+
+func (writer *htmlWriter) close(out *strings.Builder) {
+  if writer.spanOpen {
+    out.WriteString("</span>")
+    writer.spanOpen = false
+  }
+  if writer.link != ""{
+    out.WriteString("</a>")
+    writer.link = ""
+  }
+  writer.changed = true
+}
+
+
+//attributes builds the class and style attributes for a state
+func (r HTMLRenderer) attributes(state styleState) string {
+  var classes, styles []string
+  fg, bg := state.colors()
+
+  if fg.set {
+    if r.Classes && fg.index >= 0{
+      classes = append(classes, "color-fg-"+htmlColorName(fg))
+    } else {
+      styles = append(styles, "color:"+fg.hex())
+    }
+  }
+  if bg.set {
+    if r.Classes && bg.index >= 0{
+      classes = append(classes, "color-bg-"+htmlColorName(bg))
+    } else {
+      styles = append(styles, "background-color:"+bg.hex())
+    }
+  }
+  if state.reverse && !fg.set && !bg.set {
+    //nothing to swap, so invert whatever the page uses
+    classes, styles = htmlStyle(r.Classes, classes, styles, "reverse", "filter:invert(1)")
+  }
+
+  if state.bold {
+    classes, styles = htmlStyle(r.Classes, classes, styles, "bold", "font-weight:bold")
+  }
+  if state.dim {
+    classes, styles = htmlStyle(r.Classes, classes, styles, "dim", "opacity:0.5")
+  }
+  if state.italic {
+    classes, styles = htmlStyle(r.Classes, classes, styles, "italic", "font-style:italic")
+  }
+  if state.blink {
+    classes, styles = htmlStyle(r.Classes, classes, styles, "blink", "animation:color-blink 1s step-end infinite")
+  }
+  if state.hidden {
+    classes, styles = htmlStyle(r.Classes, classes, styles, "hidden", "visibility:hidden")
+  }
+
+  //underline and strike share text-decoration
+  var decoration []string
+  switch state.underline {
+  case 1:
+    classes = htmlClass(r.Classes, classes, "underline")
+    decoration = append(decoration, "underline")
+  case 2:
+    classes = htmlClass(r.Classes, classes, "underline-double")
+    decoration = append(decoration, "underline double")
+  }
+  if state.strike {
+    classes = htmlClass(r.Classes, classes, "strike")
+    decoration = append(decoration, "line-through")
+  }
+  if !r.Classes && len(decoration) > 0{
+    styles = append(styles, "text-decoration:"+strings.Join(decoration, " "))
+  }
+
+  var attr []string
+  if len(classes) > 0{
+    attr = append(attr, `class="`+strings.Join(classes, " ")+`"`)
+  }
+  if len(styles) > 0{
+    attr = append(attr, `style="`+strings.Join(styles, ";")+`"`)
+  }
+  return strings.Join(attr, " ")
+}
+
+func htmlStyle(useClasses bool, classes, styles []string, class, style string) ([]string, []string) {
+  if useClasses {
+    return append(classes, "color-"+class), styles
+  }
+  return classes, append(styles, style)
+}
+
+func htmlClass(useClasses bool, classes []string, class string) []string {
+  if useClasses {
+    return append(classes, "color-"+class)
+  }
+  return classes
+}
+
+func htmlColorName(c stateColor) string {
+  if c.name != ""{
+    return c.name
+  }
+  return fmt.Sprint(c.index)
+}
+
+
+//HTMLStylesheet returns the css for the classes HTMLRenderer uses when Classes is on:
+//the 16 named colors, the 256 palette and the text styles
+func HTMLStylesheet() string {
+  var css strings.Builder
+  for i, name := range namedColors{
+    c := namedStateColor(i)
+    fmt.Fprintf(&css, ".color-fg-%s{color:%s}\n", name, c.hex())
+    fmt.Fprintf(&css, ".color-bg-%s{background-color:%s}\n", name, c.hex())
+  }
+  for i := 0; i < 256; i++ {
+    c := paletteStateColor(i)
+    fmt.Fprintf(&css, ".color-fg-%d{color:%s}\n", i, c.hex())
+    fmt.Fprintf(&css, ".color-bg-%d{background-color:%s}\n", i, c.hex())
+  }
+  css.WriteString(`.color-bold{font-weight:bold}
+.color-dim{opacity:0.5}
+.color-italic{font-style:italic}
+.color-underline{text-decoration:underline}
+.color-underline-double{text-decoration:underline double}
+.color-strike{text-decoration:line-through}
+.color-underline.color-strike{text-decoration:underline line-through}
+.color-underline-double.color-strike{text-decoration:underline double line-through}
+.color-blink{animation:color-blink 1s step-end infinite}
+.color-reverse{filter:invert(1)}
+.color-hidden{visibility:hidden}
+@keyframes color-blink{50%{opacity:0}}
+`)
+  return css.String()
+}
+
+
+//===========================================
+//  ANSI TO HTML
+//===========================================
+
+type HTMLOptions struct {
+  //use css classes instead of inline styles, same as HTMLRenderer.Classes
+  Classes bool
+  //write a whole html document with the stylesheet and a <pre> around the output
+  Standalone bool
+  //schemes OSC 8 links may point to, same as HTMLRenderer.LinkSchemes
+  LinkSchemes []string
+}
+
+//ANSIToHTML reads terminal output with escape sequences from r and writes it to w as html
+func ANSIToHTML(r io.Reader, w io.Writer, opts HTMLOptions) error {
+  var (
+    parser ANSIParser
+    out    strings.Builder
+    writer = htmlWriter{renderer: HTMLRenderer{Classes: opts.Classes, LinkSchemes: opts.LinkSchemes}, changed: true}
+    buf    = make([]byte, 32*1024)
+  )
+  emit := func(span Span) {
+    writer.write(&out, span)
+  }
+
+  if opts.Standalone {
+    out.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+    if opts.Classes {
+      out.WriteString("<style>\n" + HTMLStylesheet() + "</style>\n")
+    }
+    out.WriteString("</head>\n<body>\n<pre>")
+  }
+
+  for {
+    n, err := r.Read(buf)
+    if n > 0 {
+      parser.Feed(buf[:n], emit)
+      if _, werr := io.WriteString(w, out.String()); werr != nil {
+        return werr
+      }
+      out.Reset()
+    }
+    if err == io.EOF {
+      break
+    }
+    if err != nil {
+      return err
+    }
+  }
+
+  parser.Flush(emit)
+  writer.close(&out)
+  if opts.Standalone {
+    out.WriteString("</pre>\n</body>\n</html>\n")
+  }
+  _, err := io.WriteString(w, out.String())
+  return err
+}
@@ -0,0 +1,149 @@
+package color
+
+import (
+  "hash/fnv"
+  "sync"
+)
+
+//===========================================
+//  COLORS FOR KEYS
+//===========================================
+
+//KeyPalette is the default palette of ForKey and fg=hash: 12 hues spread evenly
+//around OKLCH at one lightness and chroma, so no key stands out over another
+var KeyPalette = func() []Color {
+  palette := make([]Color, 12)
+  for i := range palette{
+    palette[i] = OKLCH(0.72, 0.13, 15+30*float64(i))
+  }
+  return palette
+}()
+
+//keyNamedIndices are the named colors fg=hash picks from at 16 colors,
+//leaving out black, white and the grays
+var keyNamedIndices = []int{1, 2, 3, 4, 5, 6, 9, 10, 11, 12, 13, 14}
+
+//keySlot is the slot of n the FNV hash of key points to, the same in every run.
+//with no slots at all it is 0
+func keySlot(key string, n int) int {
+  if n <= 0 {
+    return 0
+  }
+  hash := fnv.New32a()
+  hash.Write([]byte(key))
+  return int(hash.Sum32() % uint32(n))
+}
+
+//KeyColors gives every key a color from Palette. a key starts at the slot its
+//hash points to, and when another key of the session already has that slot it
+//moves on to the next free one, so collisions only happen once the palette is full.
+//the slot of a key then depends on the keys seen before it, and the session
+//remembers every key, so keep one for as long as its keys should stay apart.
+//the zero value uses KeyPalette. it is safe to use from several goroutines
+type KeyColors struct {
+  //KeyPalette when empty. when its length changes the keys seen so far keep
+  //their slots if those still exist
+  Palette []Color
+
+  mu    sync.Mutex
+  keys  map[string]int
+  taken []int
+}
+
+//NewKeyColors starts a session on palette, KeyPalette when it is empty
+func NewKeyColors(palette []Color) *KeyColors {
+  if len(palette) == 0 {
+    palette = KeyPalette
+  }
+  return &KeyColors{Palette: palette}
+}
+
+func (keys *KeyColors) palette() []Color {
+  if len(keys.Palette) == 0 {
+    return KeyPalette
+  }
+  return keys.Palette
+}
+
+//Index returns the palette slot of key, 0 when the palette is empty
+func (keys *KeyColors) Index(key string) int {
+  keys.mu.Lock()
+  defer keys.mu.Unlock()
+  return keys.index(key, len(keys.palette()))
+}
+
+func (keys *KeyColors) index(key string, n int) int {
+  if n == 0 {
+    return 0
+  }
+  if keys.keys == nil {
+    keys.keys = map[string]int{}
+  }
+  if len(keys.taken) != n {
+    keys.recount(n)
+  }
+  if index, exists := keys.keys[key]; exists {
+    return index
+  }
+
+  start := keySlot(key, n)
+
+  //the least used slot from the hash on, which is a free one while there are any
+  best := start
+  for step := 1; step < n; step++ {
+    slot := (start + step) % n
+    if keys.taken[slot] < keys.taken[best] {
+      best = slot
+    }
+  }
+  keys.keys[key] = best
+  keys.taken[best]++
+  return best
+}
+
+//recount counts the keys of each slot again for a palette of n colors, keys
+//whose slot is gone start over
+func (keys *KeyColors) recount(n int) {
+  keys.taken = make([]int, n)
+  for key, index := range keys.keys{
+    if index >= n {
+      delete(keys.keys, key)
+      continue
+    }
+    keys.taken[index]++
+  }
+}
+
+//For returns the color of key, the zero Color when the palette is empty
+func (keys *KeyColors) For(key string) Color {
+  keys.mu.Lock()
+  defer keys.mu.Unlock()
+  palette := keys.palette()
+  if len(palette) == 0 {
+    return Color{}
+  }
+  return palette[keys.index(key, len(palette))]
+}
+
+
+//ForKey maps s to a color of palette (KeyPalette when nil) by its hash alone, so
+//the same key gets the same color in every run and nothing is remembered. two keys
+//can share a color, a KeyColors session keeps the keys it has seen apart
+func ForKey(s string, palette []Color) Color {
+  if len(palette) == 0 {
+    palette = KeyPalette
+  }
+  if len(palette) == 0 {
+    return Color{}
+  }
+  return palette[keySlot(s, len(palette))]
+}
+
+//keyTag returns the markup fg=hash or bg=hash turns value into for the level.
+//at 16 colors the key gets a named color so the terminal theme still applies
+func keyTag(target, value string, level ColorLevel) string {
+  if level <= LevelBasic16 {
+    return target + "=" + namedColors[keyNamedIndices[keySlot(value, len(keyNamedIndices))]]
+  }
+  return target + "=" + ForKey(value, nil).String()
+}
@@ -0,0 +1,108 @@
+package color
+
+import (
+  "math"
+  "sync"
+)
+
+//===========================================
+//  PERCEPTUAL NEAREST COLOR
+//===========================================
+
+//Palette16 is the rgb of the 16 named colors as a terminal theme shows them,
+//in the order black, red ... lightcyan, lightwhite
+type Palette16 [16]Color
+
+//XtermPalette16 is the xterm default theme
+var XtermPalette16 = func() Palette16 {
+  var palette Palette16
+  for i, c := range namedRGB{
+    palette[i] = Color{c[0], c[1], c[2]}
+  }
+  return palette
+}()
+
+//lookup tables, filled once at start up
+var (
+  //srgb channel (0-255) to linear light
+  linearTable [256]float64
+  //oklab of palette entries 16-255, the cube and the gray ramp
+  palette256Lab [256][3]float64
+)
+
+func init() {
+  for i := range linearTable{
+    c := float64(i) / 255
+    if c <= 0.04045 {
+      linearTable[i] = c / 12.92
+    } else {
+      linearTable[i] = math.Pow((c+0.055)/1.055, 2.4)
+    }
+  }
+  for i := 16; i < 256; i++ {
+    r, g, b := paletteRGB(i)
+    palette256Lab[i] = Color{r, g, b}.oklab()
+  }
+}
+
+//oklab converts to the OKLab space, where straight distance matches how different colors look
+func (c Color) oklab() [3]float64 {
+  r, g, b := linearTable[c.R], linearTable[c.G], linearTable[c.B]
+
+  l := math.Cbrt(0.4122214708*r + 0.5363325363*g + 0.0514459929*b)
+  m := math.Cbrt(0.2119034982*r + 0.6806995451*g + 0.1073969566*b)
+  s := math.Cbrt(0.0883024619*r + 0.2817188376*g + 0.6299787005*b)
+
+  return [3]float64{
+    0.2104542553*l + 0.7936177850*m - 0.0040720468*s,
+    1.9779984951*l - 2.4285922050*m + 0.4505937099*s,
+    0.0259040371*l + 0.7827717662*m - 0.8086757660*s,
+  }
+}
+
+func labDist(a, b [3]float64) float64 {
+  dl, da, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+  return dl*dl + da*da + db*db
+}
+
+//Nearest256 returns the closest xterm 256 palette index by OKLab distance.
+//only the color cube and the gray ramp (16-255) are searched, since terminal
+//themes change the first 16
+func (c Color) Nearest256() uint8 {
+  lab := c.oklab()
+  best, bestDist := 16, math.Inf(1)
+  for i := 16; i < 256; i++ {
+    if dist := labDist(lab, palette256Lab[i]); dist < bestDist {
+      best, bestDist = i, dist
+    }
+  }
+  return uint8(best)
+}
+
+//oklab of the entries of every palette Nearest16 has seen, by palette
+var paletteLabs sync.Map
+
+//labs returns the oklab of the palette entries, converted on first use
+func (palette Palette16) labs() *[16][3]float64 {
+  if cached, exists := paletteLabs.Load(palette); exists {
+    return cached.(*[16][3]float64)
+  }
+  var labs [16][3]float64
+  for i, entry := range palette{
+    labs[i] = entry.oklab()
+  }
+  cached, _ := paletteLabs.LoadOrStore(palette, &labs)
+  return cached.(*[16][3]float64)
+}
+
+//Nearest16 returns the index (0-15) of the closest named color in palette by OKLab distance
+func (c Color) Nearest16(palette Palette16) uint8 {
+  lab := c.oklab()
+  best, bestDist := 0, math.Inf(1)
+  for i, entry := range palette.labs(){
+    if dist := labDist(lab, entry); dist < bestDist {
+      best, bestDist = i, dist
+    }
+  }
+  return uint8(best)
+}
@@ -44,6 +44,7 @@ var ResetMap = map[string]string{
   "reset":               "0",  //reset all styles
   "fg=reset":            "39", //resets foreground colors
   "bg=reset":           "49", //reset background colors
+  "ul=reset":           "59", //reset underline color
   "bold=reset": "22",
   "dim=reset": "22",
   "italic=reset": "23",
@@ -67,4 +68,34 @@ var StyleMap = map[string]string{
   "hidden": "8",
   "strike": "9" , //strike-through,
   "underline=double": "21",
-}
\ No newline at end of file
+}
+
+//the 16 named colors in palette order (index 0-15)
+var namedColors = []string{
+  "black", "red", "green", "yellow", "blue", "magenta", "cyan", "white",
+  "darkgray", "lightred", "lightgreen", "lightyellow", "lightblue", "lightmagenta", "lightcyan", "lightwhite",
+}
+
+//xterm default rgb values for the 16 named colors
+var namedRGB = [16][3]uint8{
+  {0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0}, {0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+  {127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0}, {92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+//rgb value of an index in the xterm 256 color palette
+func paletteRGB(index int) (uint8, uint8, uint8) {
+  switch {
+  case index < 16:
+    c := namedRGB[index]
+    return c[0], c[1], c[2]
+  case index < 232:
+    //6x6x6 color cube
+    levels := [6]uint8{0, 95, 135, 175, 215, 255}
+    index -= 16
+    return levels[index/36], levels[(index/6)%6], levels[index%6]
+  default:
+    //gray ramp
+    gray := uint8(8 + (index-232)*10)
+    return gray, gray, gray
+  }
+}
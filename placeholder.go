@@ -0,0 +1,188 @@
+package color
+
+import (
+  "maps"
+  "slices"
+  "strconv"
+  "strings"
+)
+
+//===========================================
+//  STYLED PLACEHOLDERS
+//===========================================
+
+/* a placeholder can carry words after its index: [0 bold fg=hash], and filters
+   (filters.go) that change the value before the words style it: [0|upper bold].
+   the words style only the value. what they set is put back right after it to
+   what the template had in effect, so the text around keeps its own colors */
+
+//valueMod is one word after the index of a styled placeholder
+type valueMod struct {
+  //fixed tags, resolved when the template is parsed
+  tags []string
+  //or tags picked from the value when the template is applied, none leaves it as it is
+  dynamic func(value string) []string
+}
+
+//restoreWord returns what undoes a tag word at a point of the template: the tag
+//the template had in effect for the same target or style, or else its reset,
+//"fg=reset" for any fg= color, "bold=reset" for bold...
+func restoreWord(tag string, ctx colorContext) string {
+  key, _, _ := strings.Cut(tag, "=")
+  if previous, exists := ctx.active[key]; exists {
+    return previous
+  }
+  if _, exists := ResetMap[key+"=reset"]; exists {
+    return key + "=reset"
+  }
+  return ""
+}
+
+//sharedResets returns the tags of ctx a reset word turns off besides its own:
+//bold=reset and dim=reset are both SGR 22, so either one ends the other too
+func sharedResets(reset string, ctx colorContext) []string {
+  code, exists := ResetMap[reset]
+  if !exists || reset == "reset" {
+    return nil
+  }
+  var tags []string
+  for _, key := range slices.Sorted(maps.Keys(ctx.active)){
+    if key+"=reset" != reset && ResetMap[key+"=reset"] == code {
+      tags = append(tags, ctx.active[key])
+    }
+  }
+  return tags
+}
+
+//parsePlaceholder reads a tag like "0 bold fg=hash" or "0|upper|trunc:8".
+//plain "[0]" is left to Parse
+func (toggle *ColorToggle) parsePlaceholder(content string, ctx colorContext) (TempPart, bool) {
+  words := tagWords(content)
+  if len(words) == 0 {
+    return TempPart{}, false
+  }
+  chain := splitFilters(words[0])
+  if (len(words) < 2 && len(chain) < 2) || !allDigits(chain[0]) {
+    return TempPart{}, false
+  }
+  index, err := strconv.Atoi(chain[0])
+  if err != nil || index > 999 {
+    return TempPart{}, false
+  }
+  filters, err := parseFilters(chain[1:])
+  if err != nil {
+    return TempPart{}, false
+  }
+  value := TempPart{Index: index, filters: filters}
+  if len(words) == 1 {
+    return value, true
+  }
+
+  var (
+    mods  []valueMod
+    level = toggle.Level
+    cvd   = toggle.CVDMode
+  )
+  //tags picked at Apply time get the same remapping tagPart gives the fixed ones
+  dynamic := func(pick func(value string) []string) func(string) []string {
+    return func(value string) []string {
+      tags := pick(value)
+      remapped := make([]string, len(tags))
+      for i, tag := range tags{
+        remapped[i] = cvd.remapTag(tag, level)
+      }
+      return remapped
+    }
+  }
+  //the template goes on with outside, the mods change a copy
+  outside := ctx
+  outside.active = maps.Clone(ctx.active)
+  ctx.active = maps.Clone(ctx.active)
+  for _, word := range words[1:]{
+    target, value, _ := strings.Cut(word, "=")
+    switch _, isTarget := targetPrefixes[target]; {
+    case isTarget && value == "hash":
+      mods = append(mods, valueMod{dynamic: dynamic(func(value string) []string {
+        return []string{keyTag(target, value, level)}
+      })})
+    case target == "scale" || target == "heat":
+      pick, err := toggle.parseScale(target, value)
+      if err != nil {
+        return TempPart{}, false
+      }
+      mods = append(mods, valueMod{dynamic: dynamic(pick)})
+    case target == "map":
+      pick, err := toggle.parseValueMap(value, ctx)
+      if err != nil {
+        return TempPart{}, false
+      }
+      mods = append(mods, valueMod{dynamic: pick})
+    case IsSupportedColor(word):
+      mods = append(mods, valueMod{tags: []string{toggle.tagPart(word, &ctx).Tag}})
+    default:
+      return TempPart{}, false
+    }
+  }
+
+  var fixed []string
+  for _, mod := range mods{
+    fixed = append(fixed, mod.tags...)
+  }
+
+  //every tag is remapped already, so the block has no cvd of its own
+  block := &tagBlock{
+    name: "placeholder",
+    tag: blockTag{render: func(arg string, inner []Span) []Span {
+      return styleValue(mods, outside, inner)
+    }},
+    inner:   CompiledTemplate{Parts: []TempPart{value}},
+    fixed:   fixed,
+    level:   toggle.Level,
+    palette: toggle.Palette,
+  }
+  return TempPart{Index: -1, block: block}, true
+}
+
+//styleValue wraps the value spans in the tags of the mods, then puts back
+//what the template had in effect outside
+func styleValue(mods []valueMod, outside colorContext, inner []Span) []Span {
+  var value strings.Builder
+  for _, span := range inner{
+    value.WriteString(span.Text)
+  }
+
+  var tags []string
+  for _, mod := range mods{
+    if mod.dynamic != nil {
+      tags = append(tags, mod.dynamic(value.String())...)
+    } else {
+      tags = append(tags, mod.tags...)
+    }
+  }
+
+  spans := make([]Span, 0, 2*len(tags)+len(inner))
+  for _, tag := range tags{
+    spans = append(spans, Span{Tag: tag})
+  }
+  spans = append(spans, inner...)
+
+  done := map[string]bool{}
+  var resets []string
+  for _, tag := range tags{
+    if restore := restoreWord(tag, outside); restore != "" && !done[restore] {
+      spans = append(spans, Span{Tag: restore})
+      done[restore] = true
+      resets = append(resets, restore)
+    }
+  }
+  //what the resets took with them from outside goes back on
+  for _, reset := range resets{
+    for _, tag := range sharedResets(reset, outside){
+      if !done[tag] {
+        spans = append(spans, Span{Tag: tag})
+        done[tag] = true
+      }
+    }
+  }
+  return spans
+}
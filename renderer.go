@@ -0,0 +1,126 @@
+package color
+
+import "strings"
+
+//===========================================
+//  RENDERERS
+//===========================================
+
+//a span is either a style tag ("fg=red", "bold", "reset") or a run of text.
+//a compiled template is turned into spans before it goes through a renderer
+type Span struct {
+  Tag  string
+  Text string
+}
+
+//Renderer turns the spans of an applied template into output.
+//third party backends only need to implement this
+type Renderer interface {
+  Render(spans []Span) string
+}
+
+//ANSIRenderer writes terminal escape sequences for the level of Toggle, same as
+//Apply does. with LevelNone it writes only the text
+type ANSIRenderer struct {
+  //nil is the toggle the template was parsed with in CompiledTemplate.Render, and
+  //a detected one (NO_COLOR, the tty, TERM) for spans rendered on their own
+  Toggle *ColorToggle
+}
+
+//PlainRenderer drops every tag and keeps only the text
+type PlainRenderer struct{}
+
+//DebugRenderer writes tags as visible markers like <fg=red> instead of escapes.
+//useful for log files and test failures
+type DebugRenderer struct{}
+
+func (r ANSIRenderer) Render(spans []Span) string {
+  toggle := r.Toggle
+  if toggle == nil {
+    toggle = NewColorToggle()
+  }
+  var result strings.Builder
+  for _, span := range spans{
+    if span.Tag != ""{
+      if toggle.Level != LevelNone {
+        result.WriteString(tagCode(span.Tag, toggle.Level, toggle.Palette))
+      }
+    } else {
+      result.WriteString(span.Text)
+    }
+  }
+  return result.String()
+}
+
+//tagCode is the escape sequence of a span tag for the level. link=url tags, which
+//block tags and FromANSI make, become OSC 8 hyperlinks. palette is the one of
+//the toggle, nil for XtermPalette16
+func tagCode(tag string, level ColorLevel, palette *Palette16) string {
+  if target, isLink := strings.CutPrefix(tag, "link="); isLink {
+    if level == LevelNone {
+      return ""
+    }
+    if target == "reset" {
+      target = ""
+    }
+    return "\033]8;;" + target + "\033\\"
+  }
+  return parseColorLevel(tag, level, palette)
+}
+
+func (PlainRenderer) Render(spans []Span) string {
+  var result strings.Builder
+  for _, span := range spans{
+    if span.Tag == ""{
+      result.WriteString(span.Text)
+    }
+  }
+  return result.String()
+}
+
+func (DebugRenderer) Render(spans []Span) string {
+  var result strings.Builder
+  for _, span := range spans{
+    if span.Tag != ""{
+      result.WriteString("<" + span.Tag + ">")
+    } else {
+      result.WriteString(span.Text)
+    }
+  }
+  return result.String()
+}
+
+
+//Spans fills the placeholders with args and returns the template as spans
+func (temp CompiledTemplate) Spans(args ...any) []Span {
+  spans := make([]Span, 0, len(temp.Parts))
+  for _, part := range temp.Parts{
+    if part.block != nil {
+      spans = append(spans, part.block.spans(args)...)
+    } else if part.Index < 0{
+      if part.Tag != ""{
+        spans = append(spans, Span{Tag: part.Tag})
+      } else if part.Text != ""{
+        spans = append(spans, Span{Text: part.Text})
+      }
+    } else if value, ok := part.value(args); ok {
+      spans = append(spans, Span{Text: value})
+    }
+  }
+  return spans
+}
+
+
+//Render is like Apply but the output goes through the given renderer. only an
+//ANSIRenderer (the one used when r is nil) without a toggle of its own takes the
+//toggle the template was parsed with, so redirected output stays plain
+func (temp CompiledTemplate) Render(r Renderer, args ...any) string {
+  if r == nil {
+    r = ANSIRenderer{}
+  }
+  if ansi, isANSI := r.(ANSIRenderer); isANSI && ansi.Toggle == nil {
+    ansi.Toggle = temp.toggle
+    r = ansi
+  }
+  return r.Render(temp.Spans(args...))
+}
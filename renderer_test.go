@@ -0,0 +1,367 @@
+package color
+
+import (
+  "io"
+  "strings"
+  "testing"
+)
+
+func TestRenderers(t *testing.T){
+  temp := NewColorToggle(false).Parse("[bold fg=red]Hello [0][reset]")
+
+  if got := temp.Render(DebugRenderer{}, "World"); got != "<bold><fg=red>Hello World<reset>" {
+    t.Errorf("debug renderer gave %q", got)
+  }
+  if got := temp.Render(PlainRenderer{}, "World"); got != "Hello World" {
+    t.Errorf("plain renderer gave %q", got)
+  }
+  //the ansi renderer follows the toggle of the template, or its own
+  if got := temp.Render(ANSIRenderer{}, "World"); got != "Hello World" {
+    t.Errorf("ansi renderer with color off gave %q", got)
+  }
+  if got := temp.Render(ANSIRenderer{Toggle: NewColorToggleLevel(LevelBasic16)}, "World"); got != "\033[1m\033[31mHello World\033[0m" {
+    t.Errorf("ansi renderer gave %q", got)
+  }
+  if got := NewColorToggleLevel(LevelNone).Parse("[fg=#ff0000]x").Render(nil); got != "x" {
+    t.Errorf("nil renderer with LevelNone gave %q", got)
+  }
+  //toggle is off so Apply stays plain
+  if got := temp.Apply("World"); got != "Hello World" {
+    t.Errorf("apply gave %q", got)
+  }
+}
+
+func TestHTMLRenderer(t *testing.T){
+  temp := Parse("[bold fg=red]<b>[0][reset] [fg=#AABBCC]hex[reset]")
+
+  inline := temp.Render(HTMLRenderer{}, "&")
+  want := `<span style="color:#cd0000;font-weight:bold">&lt;b&gt;&amp;</span> <span style="color:#aabbcc">hex</span>`
+  if inline != want {
+    t.Errorf("inline html gave %q", inline)
+  }
+
+  classes := temp.Render(HTMLRenderer{Classes: true}, "&")
+  want = `<span class="color-fg-red color-bold">&lt;b&gt;&amp;</span> <span style="color:#aabbcc">hex</span>`
+  if classes != want {
+    t.Errorf("class html gave %q", classes)
+  }
+}
+
+//reader that hands out one byte at a time so every sequence gets split
+type byteReader struct {
+  data []byte
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+  if len(r.data) == 0 {
+    return 0, io.EOF
+  }
+  p[0] = r.data[0]
+  r.data = r.data[1:]
+  return 1, nil
+}
+
+func TestANSIToHTML(t *testing.T){
+  input := "\033[1;38;5;196mred\033[0m \033[48:2::0:0:255mblue\033[49m é \033]8;;https://example.com\033\\link\033]8;;\a"
+  var out strings.Builder
+  if err := ANSIToHTML(&byteReader{data: []byte(input)}, &out, HTMLOptions{}); err != nil {
+    t.Fatal(err)
+  }
+  want := `<span style="color:#ff0000;font-weight:bold">red</span> <span style="background-color:#0000ff">blue</span> é <a href="https://example.com">link</a>`
+  if out.String() != want {
+    t.Errorf("ansi to html gave %q", out.String())
+  }
+}
+
+func TestHTMLLinks(t *testing.T){
+  var out strings.Builder
+  input := "\033]8;;javascript:alert(1)\033\\click\033]8;;\033\\ \033]8;;JavaScript:x\033\\b\033]8;;\033\\ \033]8;;https://example.com\033\\ok\033]8;;\033\\"
+  if err := ANSIToHTML(strings.NewReader(input), &out, HTMLOptions{}); err != nil {
+    t.Fatal(err)
+  }
+  if want := `click b <a href="https://example.com">ok</a>`; out.String() != want {
+    t.Errorf("ansi to html links gave %q", out.String())
+  }
+
+  //tags from block handlers go through the same check
+  spans := []Span{{Tag: "link=javascript:alert(1)"}, {Text: "x"}, {Tag: "link=reset"}, {Tag: "link=ftp://host/f"}, {Text: "y"}}
+  if got := (HTMLRenderer{}).Render(spans); got != "xy" {
+    t.Errorf("html renderer gave %q", got)
+  }
+  if got := (HTMLRenderer{LinkSchemes: []string{"ftp"}}).Render(spans); got != `x<a href="ftp://host/f">y</a>` {
+    t.Errorf("custom schemes gave %q", got)
+  }
+}
+
+func TestANSITruncated(t *testing.T){
+  html := func(input string) string {
+    var out strings.Builder
+    if err := ANSIToHTML(&byteReader{data: []byte(input)}, &out, HTMLOptions{}); err != nil {
+      t.Fatal(err)
+    }
+    return out.String()
+  }
+  //what follows an unterminated sequence is kept as text
+  if got := html("before\033]0;title never terminated\nline2\n"); got != "before0;title never terminated\nline2\n" {
+    t.Errorf("cut osc gave %q", got)
+  }
+  if got := html("a\033[1;3"); got != "a1;3" {
+    t.Errorf("cut csi gave %q", got)
+  }
+  if got := html("a\033"); got != "a" {
+    t.Errorf("lone escape gave %q", got)
+  }
+  //three byte escapes are dropped whole
+  if got := html("\033(Bok"); got != "ok" {
+    t.Errorf("charset escape gave %q", got)
+  }
+  //a sequence too long to wait for is text
+  long := "\033]0;" + strings.Repeat("x", maxPendingSequence) + "\033[1mb"
+  if got := html(long); got != "0;"+strings.Repeat("x", maxPendingSequence)+"<span style=\"font-weight:bold\">b</span>" {
+    t.Errorf("long osc gave %.40q...", got)
+  }
+}
+
+func TestSVGLayout(t *testing.T){
+  spans := Parse("[fg=red]ab[reset]\n漢x").Spans()
+  lines, cols := svgLines(spans)
+  if len(lines) != 2 || cols != 3 {
+    t.Fatalf("got %d lines and %d cols", len(lines), cols)
+  }
+  //the wide character takes two cells
+  if lines[1][0].cells != 3 || lines[0][0].state.fg.name != "red" {
+    t.Errorf("unexpected runs %+v", lines)
+  }
+
+  svg := Parse("[bold]<hi>[reset]").Render(SVGRenderer{Options: SVGOptions{Window: true, Title: "demo"}})
+  if !strings.Contains(svg, `font-weight="bold"`) || !strings.Contains(svg, "&lt;hi&gt;") || !strings.Contains(svg, "demo") {
+    t.Errorf("unexpected svg %s", svg)
+  }
+}
+
+func TestFromANSI(t *testing.T){
+  input := "\033[1m\033[1m\033[31mError\033[0m\033[0m: \033[38;2;170;187;204mhex\033[39m [x] \033[2K\033[38;5;214mdone\033[m"
+  markup, err := FromANSI(input)
+  if err != nil {
+    t.Fatal(err)
+  }
+  want := "[bold fg=red]Error[reset]: [fg=#aabbcc]hex[reset][raw] [x] \033[2K[/raw][fg=214]done[reset]"
+  if markup != want {
+    t.Errorf("from ansi gave %q", markup)
+  }
+  //the markup renders back to the same text
+  if got := NewColorToggle(false).Parse(markup).Apply(); got != "Error: hex [x] \033[2Kdone" {
+    t.Errorf("round trip gave %q", got)
+  }
+
+  //text holding [/raw] comes back whole
+  markup, err = FromANSI("a[/raw]b \033[31mred\033[0m")
+  if err != nil {
+    t.Fatal(err)
+  }
+  if got := NewColorToggleLevel(LevelBasic16).Parse(markup).Apply(); got != "a[/raw]b \033[31mred\033[0m" {
+    t.Errorf("raw round trip gave %q from %q", got, markup)
+  }
+
+  if _, err := FromANSI("cut \033[3"); err == nil {
+    t.Error("expected an error for a cut sequence")
+  }
+}
+
+func TestGradient(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+
+  temp := toggle.Parse("[gradient=#ff0000:#0000ff]a[0]c[/gradient]!")
+  want := "<fg=#ff0000>a<fg=#8c53a2>b<fg=#0000ff>c<fg=reset>!"
+  if got := temp.Render(DebugRenderer{}, "b"); got != want {
+    t.Errorf("gradient gave %q, want %q", got, want)
+  }
+  //the fg in effect before the block comes back after it
+  if got := toggle.Parse("[fg=red]a[gradient=#000:#fff]bc[/gradient]d").Render(DebugRenderer{}); got != "<fg=red>a<fg=#000000>b<fg=#ffffff>c<fg=red>d" {
+    t.Errorf("gradient after fg gave %q", got)
+  }
+  //a block after other text and tags
+  if got := toggle.Parse("x[bold][rainbow]a[/rainbow]").Render(PlainRenderer{}); got != "xa" {
+    t.Errorf("later block gave %q", got)
+  }
+  //the placeholder is filled before the colors are picked
+  if got := temp.Render(PlainRenderer{}, "xyz"); got != "axyzc!" {
+    t.Errorf("plain gave %q", got)
+  }
+
+  //combining marks stay with their letter, spaces get no color of their own
+  got := toggle.Parse("[vgradient=black:white]é x\ny[/vgradient]").Render(DebugRenderer{})
+  want = "<fg=#000000>é x\n<fg=#e5e5e5>y<fg=reset>"
+  if got != want {
+    t.Errorf("vertical gradient gave %q, want %q", got, want)
+  }
+
+  //at 16 colors neighbouring graphemes share one code
+  if got := NewColorToggleLevel(LevelBasic16).Parse("[gradient=#ff0000:#ff1010]abc[/gradient]").Apply(); got != "\033[91mabc\033[39m" {
+    t.Errorf("16 color gradient gave %q", got)
+  }
+
+  if got := toggle.Parse("[rainbow]ab[/rainbow]").Render(PlainRenderer{}); got != "ab" {
+    t.Errorf("rainbow gave %q", got)
+  }
+  //no close, or a bad stop, leaves the tag as text
+  if got := toggle.Parse("[gradient=#ff0000:nope]a[/gradient]").Apply(); got != "[gradient=#ff0000:nope]a[/gradient]" {
+    t.Errorf("bad gradient gave %q", got)
+  }
+}
+
+func TestRegisterTag(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  got := toggle.Parse("[upper]ab[fg=red]c[/upper] [pad=5]x[/pad]|[lpad=3][0][/lpad]|[center=6]ab[/center]|").Render(DebugRenderer{}, 7)
+  if got != "AB<fg=red>C x    |  7|  ab  |" {
+    t.Errorf("text blocks gave %q", got)
+  }
+
+  RegisterTag("ticket", func(arg string, inner []Span) []Span {
+    var id strings.Builder
+    for _, span := range inner{
+      id.WriteString(span.Text)
+    }
+    spans := []Span{{Tag: "link=https://tracker.example.com/" + id.String()}, {Tag: "underline=single"}}
+    spans = append(spans, inner...)
+    return append(spans, Span{Tag: "underline=reset"}, Span{Tag: "link=reset"})
+  })
+  t.Cleanup(func() {
+    blockTagsMu.Lock()
+    defer blockTagsMu.Unlock()
+    delete(blockTags, "ticket")
+  })
+  temp := toggle.Parse("see [ticket][0][/ticket]")
+  want := "see \033]8;;https://tracker.example.com/ABC-123\033\\\033[4mABC-123\033[24m\033]8;;\033\\"
+  if got := temp.Apply("ABC-123"); got != want {
+    t.Errorf("ticket gave %q", got)
+  }
+  if got := temp.Render(HTMLRenderer{}, "ABC-123"); !strings.Contains(got, `href="https://tracker.example.com/ABC-123"`) {
+    t.Errorf("ticket html gave %q", got)
+  }
+
+  //names that aren't registered stay text
+  if got := toggle.Parse("[nope]x[/nope]").Apply(); got != "[nope]x[/nope]" {
+    t.Errorf("unknown tag gave %q", got)
+  }
+  //words templates already use, and names Parse can't read back
+  for _, name := range []string{"bold", "reset", "raw", "link", "fg", "underline", "blink", "red", "lightcyan", "tomato", "scale", "heat", "map", "hash", "a=b", "a|b", "a/b", "a b", "a\tb", ""}{
+    func() {
+      defer func() {
+        if recover() == nil {
+          t.Errorf("registering %q should panic", name)
+        }
+      }()
+      RegisterTag(name, func(string, []Span) []Span { return nil })
+    }()
+  }
+}
+
+func TestTypedTemplates(t *testing.T){
+  line := MustParse2[string, int]("[fg=red][0 bold]: [1][1 scale=50:green]")
+  if got := line.Render(PlainRenderer{}, "score", 7); got != "score: 77" {
+    t.Errorf("typed gave %q", got)
+  }
+  if got := MustParse1[int]("[gradient=red:blue][0][/gradient]").Template().Slots(); len(got) != 1 || got[0] != 0 {
+    t.Errorf("slots in a block gave %v", got)
+  }
+
+  for _, bad := range []string{"[0] [2]", "[0]", "[0] [1] [2]", "no slots"}{
+    if _, err := Parse2[string, string](bad); err == nil {
+      t.Errorf("%q should not make a T2", bad)
+    }
+  }
+  defer func() {
+    if recover() == nil {
+      t.Error("MustParse3 should panic")
+    }
+  }()
+  MustParse3[int, int, int]("[0][1]")
+}
+
+func TestBind(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  temp := toggle.Parse("[fg=red][0|upper] [1 bold] [2][reset]")
+
+  bound := temp.Bind(0, "api")
+  if got, want := bound.Apply("x", "y"), temp.Apply("api", "x", "y"); got != want {
+    t.Errorf("renumbered gave %q, want %q", got, want)
+  }
+  if got := bound.Slots(); len(got) != 2 || got[1] != 1 {
+    t.Errorf("slots after bind %v", got)
+  }
+
+  kept := temp.BindMap(map[int]any{0: "api", 1: "db"}, KeepNumbers())
+  if got := kept.Render(DebugRenderer{}, nil, nil, "z"); got != "<fg=red>API <bold>db<bold=reset> z<reset>" {
+    t.Errorf("kept gave %q", got)
+  }
+  if got, want := kept.Apply(nil, nil, "z"), temp.Apply("api", "db", "z"); got != want {
+    t.Errorf("kept apply gave %q, want %q", got, want)
+  }
+
+  //a block with a slot left keeps rendering at Apply time
+  gradient := toggle.Parse("[gradient=#ff0000:#0000ff][0][1][/gradient]").Bind(0, "a")
+  if got := gradient.Render(DebugRenderer{}, "b"); got != "<fg=#ff0000>a<fg=#0000ff>b<fg=reset>" {
+    t.Errorf("partly bound block gave %q", got)
+  }
+}
+
+func TestCompose(t *testing.T){
+  toggle := NewColorToggleLevel(LevelTrueColor)
+  name := toggle.Parse("[fg=red bold][0]")
+  value := toggle.Parse("[0|upper] [1]")
+
+  joined := Join(" | ", name, value, Text("[literal]"))
+  if got := joined.Render(DebugRenderer{}, "a", "b", "c"); got != "<fg=red><bold>a<bold=reset><fg=reset> | B c | [literal]" {
+    t.Errorf("join gave %q", got)
+  }
+  if got := joined.Apply("a", "b", "c"); got != "\033[31m\033[1ma\033[22m\033[39m | B c | [literal]" {
+    t.Errorf("join apply gave %q", got)
+  }
+
+  wrapped := Wrap("italic fg=cyan", value)
+  if got := wrapped.Render(DebugRenderer{}, "x", "y"); got != "<italic><fg=cyan>X y<fg=reset><italic=reset>" {
+    t.Errorf("wrap gave %q", got)
+  }
+
+  if got := Repeat(toggle.Parse("[0]-"), 3).Apply(1, 2, 3); got != "1-2-3-" {
+    t.Errorf("repeat gave %q", got)
+  }
+  if got := Concat(value, name).Slots(); len(got) != 3 || got[2] != 2 {
+    t.Errorf("concat slots %v", got)
+  }
+  //styles left on inside a block don't leak into the next template
+  if got := Concat(toggle.Parse("[upper][bold]x[/upper]"), toggle.Parse("y")).Render(DebugRenderer{}); got != "<bold>X<bold=reset>y" {
+    t.Errorf("concat after block gave %q", got)
+  }
+
+  //Wrap parses its style with the template's own toggle
+  cvd := NewColorToggleLevel(LevelAnsi256)
+  cvd.CVDMode = Deuteranopia
+  if got := Wrap("fg=green", cvd.Parse("[0]")).Apply("x"); got != "\033[38;5;36mx\033[39m" {
+    t.Errorf("wrap with cvd gave %q", got)
+  }
+  //pieces inside a Wrap close back to the wrapper's styles
+  if got := Wrap("bold", Concat(toggle.Parse("[bold]a"), toggle.Parse("b"))).Render(DebugRenderer{}); got != "<bold><bold>ab<bold=reset>" {
+    t.Errorf("Wrap(Concat) same style = %q", got)
+  }
+  if got := Wrap("fg=red", Concat(toggle.Parse("[fg=blue]a"), toggle.Parse("b"))).Render(DebugRenderer{}); got != "<fg=red><fg=blue>a<fg=red>b<fg=reset>" {
+    t.Errorf("Wrap(Concat) other color = %q", got)
+  }
+  //dim=reset is SGR 22 like bold=reset, so the wrapper's bold comes back after it
+  if got := Wrap("bold", Concat(toggle.Parse("[dim]a"), toggle.Parse("b"))).Render(DebugRenderer{}); got != "<bold><dim>a<dim=reset><bold>b<bold=reset>" {
+    t.Errorf("Wrap(Concat) dim = %q", got)
+  }
+  if got := Wrap("bold", Concat(toggle.Parse("[dim]a"), toggle.Parse("b"))).Apply(); got != "\033[1m\033[2ma\033[22m\033[1mb\033[22m" {
+    t.Errorf("Wrap(Concat) dim Apply = %q", got)
+  }
+  //a nested Wrap closes back to its own styles, the outer one to the rest
+  nested := Wrap("italic", Concat(Wrap("bold", Concat(toggle.Parse("[fg=blue]a"), toggle.Parse("b"))), toggle.Parse("c")))
+  if got := nested.Render(DebugRenderer{}); got != "<italic><bold><fg=blue>a<fg=reset>b<bold=reset>c<italic=reset>" {
+    t.Errorf("nested Wrap = %q", got)
+  }
+  literal := CompiledTemplate{Parts: []TempPart{{Text: "x", Index: -1}}}
+  if got := Wrap("bold", literal).Render(DebugRenderer{}); got != "<bold>x<bold=reset>" {
+    t.Errorf("wrap of a literal template gave %q", got)
+  }
+}
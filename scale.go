@@ -0,0 +1,132 @@
+package color
+
+import (
+  "fmt"
+  "slices"
+  "strconv"
+  "strings"
+)
+
+//===========================================
+//  SCALES
+//===========================================
+
+/* scales pick the fg of a placeholder from its value when the template is applied:
+
+     [0 scale=90:green,70:yellow,0:red]   the color of the highest threshold the value reaches
+     [0 scale=90:green+bold,0:red]        a step can add styles to its color with +
+     [0 heat=0:100:green:yellow:red]      min and max, then colors the value is mixed along
+
+   values that aren't numbers (NaN and infinities included), or are below every
+   threshold, are left uncolored */
+
+type scaleStep struct {
+  threshold float64
+  tags      []string
+}
+
+//scaleColor reads one color of a scale. alpha is composited over the toggle background
+func (toggle *ColorToggle) scaleColor(value string) (Color, string, error) {
+  spec, err := ParseColorSpec("fg=" + value)
+  if err != nil || spec.Target != TargetFg || spec.Kind == KindReset || spec.Kind == KindAuto {
+    return Color{}, "", fmt.Errorf("color: scale color %q is not a color", value)
+  }
+  if spec.Alpha < 1 {
+    spec.Color = spec.Color.Blend(toggle.Background, spec.Alpha)
+    spec.Kind, spec.Alpha = KindHex, 1
+  }
+  return spec.Color, spec.String(), nil
+}
+
+//parseScale reads the value of a scale= or heat= word into the function that
+//picks the tags for a value
+func (toggle *ColorToggle) parseScale(kind, arg string) (func(value string) []string, error) {
+  if kind == "heat" {
+    return toggle.parseHeat(arg)
+  }
+
+  var steps []scaleStep
+  for _, entry := range strings.Split(arg, ","){
+    number, value, found := strings.Cut(entry, ":")
+    threshold, err := strconv.ParseFloat(number, 64)
+    if !found || err != nil || !isFinite(threshold) {
+      return nil, fmt.Errorf("color: scale step %q is not threshold:color", entry)
+    }
+    //color+style+style...
+    words := strings.Split(value, "+")
+    _, tag, err := toggle.scaleColor(words[0])
+    if err != nil {
+      return nil, err
+    }
+    tags := []string{tag}
+    for _, style := range words[1:]{
+      if _, isStyle := StyleMap[style]; !isStyle {
+        return nil, fmt.Errorf("color: scale step %q: %q is not a style", entry, style)
+      }
+      tags = append(tags, style)
+    }
+    steps = append(steps, scaleStep{threshold, tags})
+  }
+  //highest threshold first
+  slices.SortStableFunc(steps, func(a, b scaleStep) int {
+    switch {
+    case a.threshold > b.threshold:
+      return -1
+    case a.threshold < b.threshold:
+      return 1
+    }
+    return 0
+  })
+
+  return func(value string) []string {
+    number, ok := scaleValue(value)
+    if !ok {
+      return nil
+    }
+    for _, step := range steps{
+      if number >= step.threshold {
+        return step.tags
+      }
+    }
+    return nil
+  }, nil
+}
+
+func (toggle *ColorToggle) parseHeat(arg string) (func(value string) []string, error) {
+  fields := strings.SplitN(arg, ":", 3)
+  if len(fields) < 3 {
+    return nil, fmt.Errorf("color: heat %q is not min:max:colors", arg)
+  }
+  low, errLow := strconv.ParseFloat(fields[0], 64)
+  high, errHigh := strconv.ParseFloat(fields[1], 64)
+  if errLow != nil || errHigh != nil || !isFinite(low) || !isFinite(high) || low == high {
+    return nil, fmt.Errorf("color: heat %q needs two different numbers for its range", arg)
+  }
+  //read one by one, so alpha is composited like in scale=
+  var stops []Color
+  for _, value := range splitStops(fields[2]){
+    c, _, err := toggle.scaleColor(value)
+    if err != nil {
+      return nil, err
+    }
+    stops = append(stops, c)
+  }
+  if len(stops) < 2 {
+    return nil, fmt.Errorf("color: heat %q needs at least 2 colors", arg)
+  }
+
+  return func(value string) []string {
+    number, ok := scaleValue(value)
+    if !ok {
+      return nil
+    }
+    return []string{gradientAt(stops, (number-low)/(high-low)).Fg()}
+  }, nil
+}
+
+//scaleValue reads the value of a placeholder as a number, "85%" included.
+//NaN and the infinities, which ParseFloat takes, are not numbers here
+func scaleValue(value string) (float64, bool) {
+  number, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+  return number, err == nil && isFinite(number)
+}
@@ -0,0 +1,133 @@
+package color
+
+import (
+  "fmt"
+  "strings"
+)
+
+//===========================================
+//  STYLE STATE
+//===========================================
+
+//styleState tracks which colors and styles are active while walking spans.
+//renderers that can't just print escapes (html, svg) work from this
+type styleState struct {
+  fg, bg    stateColor
+  bold      bool
+  dim       bool
+  italic    bool
+  underline int //0 = none, 1 = single, 2 = double
+  blink     bool
+  reverse   bool
+  hidden    bool
+  strike    bool
+  link      string //target of an OSC 8 hyperlink
+}
+
+type stateColor struct {
+  set   bool
+  name  string //one of the 16 named colors, empty otherwise
+  index int    //palette index (0-255), -1 for hex and rgb colors
+  r, g, b uint8
+}
+
+func (c stateColor) hex() string {
+  return fmt.Sprintf("#%02x%02x%02x", c.r, c.g, c.b)
+}
+
+func namedStateColor(index int) stateColor {
+  r, g, b := paletteRGB(index)
+  return stateColor{set: true, name: namedColors[index], index: index, r: r, g: g, b: b}
+}
+
+func paletteStateColor(index int) stateColor {
+  if index < 16 {
+    return namedStateColor(index)
+  }
+  r, g, b := paletteRGB(index)
+  return stateColor{set: true, index: index, r: r, g: g, b: b}
+}
+
+//tagColor reads the color out of a fg=/bg= tag
+func tagColor(tag string) (stateColor, bool) {
+  spec, err := ParseColorSpec(tag)
+  if err != nil || (spec.Target != TargetFg && spec.Target != TargetBg) {
+    return stateColor{}, false
+  }
+  switch spec.Kind {
+  case KindNamed:
+    return namedStateColor(int(spec.Index)), true
+  case KindPalette:
+    return paletteStateColor(int(spec.Index)), true
+  case KindStyle, KindReset:
+    return stateColor{}, false
+  }
+  return stateColor{set: true, index: -1, r: spec.Color.R, g: spec.Color.G, b: spec.Color.B}, true
+}
+
+
+//apply updates the state with one style tag. unknown tags are ignored
+func (state *styleState) apply(tag string) {
+  switch tag {
+  case "reset":
+    //SGR 0 doesn't end a hyperlink
+    *state = styleState{link: state.link}
+  case "link=reset":
+    state.link = ""
+  case "fg=reset":
+    state.fg = stateColor{}
+  case "bg=reset":
+    state.bg = stateColor{}
+  case "bold=reset", "dim=reset":
+    //both share code 22
+    state.bold, state.dim = false, false
+  case "italic=reset":
+    state.italic = false
+  case "underline=reset":
+    state.underline = 0
+  case "blink=reset", "blinkfast=reset":
+    state.blink = false
+  case "reverse=reset":
+    state.reverse = false
+  case "hidden=reset":
+    state.hidden = false
+  case "strike=reset":
+    state.strike = false
+  case "bold":
+    state.bold = true
+  case "dim":
+    state.dim = true
+  case "italic":
+    state.italic = true
+  case "underline=single":
+    state.underline = 1
+  case "underline=double":
+    state.underline = 2
+  case "blink=slow", "blink=fast":
+    state.blink = true
+  case "reverse":
+    state.reverse = true
+  case "hidden":
+    state.hidden = true
+  case "strike":
+    state.strike = true
+  default:
+    if strings.HasPrefix(tag, "link="){
+      state.link = tag[5:]
+    } else if c, ok := tagColor(tag); ok{
+      if strings.HasPrefix(tag, "fg="){
+        state.fg = c
+      } else {
+        state.bg = c
+      }
+    }
+  }
+}
+
+//colors returns fg and bg with reverse already applied
+func (state styleState) colors() (stateColor, stateColor) {
+  if state.reverse {
+    return state.bg, state.fg
+  }
+  return state.fg, state.bg
+}
@@ -0,0 +1,240 @@
+package color
+
+import (
+  "fmt"
+  "html"
+  "io"
+  "strings"
+)
+
+//===========================================
+//  SVG RENDERER
+//===========================================
+
+//SVGTheme holds the colors a "terminal screenshot" is drawn with. colors are #rrggbb
+type SVGTheme struct {
+  Foreground string
+  Background string
+  //the 16 named colors, in the order black, red ... lightcyan, lightwhite
+  Palette [16]string
+}
+
+type SVGOptions struct {
+  FontFamily string  //defaults to a monospace stack
+  FontSize   float64 //in px, defaults to 14
+  LineHeight float64 //multiple of FontSize, defaults to 1.4
+  Padding    float64 //in px, defaults to 16
+  Window     bool    //draw a window title bar with the three buttons
+  Title      string  //shown in the title bar when Window is on
+  Theme      *SVGTheme
+}
+
+//DefaultSVGTheme is a dark theme with the xterm colors
+var DefaultSVGTheme = func() SVGTheme {
+  theme := SVGTheme{Foreground: "#d4d4d4", Background: "#1e1e1e"}
+  for i := range theme.Palette{
+    theme.Palette[i] = namedStateColor(i).hex()
+  }
+  return theme
+}()
+
+//SVGRenderer lays the rendered template out on a monospace grid and returns an svg image
+type SVGRenderer struct {
+  Options SVGOptions
+}
+
+func (r SVGRenderer) Render(spans []Span) string {
+  var out strings.Builder
+  WriteSVG(&out, spans, r.Options)
+  return out.String()
+}
+
+
+//a run of text on one line that shares one style
+type svgRun struct {
+  col   int
+  cells int
+  text  string
+  state styleState
+}
+
+//svgLines splits spans into lines of styled runs
+func svgLines(spans []Span) ([][]svgRun, int) {
+  var (
+    state   styleState
+    lines   = [][]svgRun{nil}
+    col     = 0
+    maxCols = 0
+  )
+
+  addRun := func(text string) {
+    if text == ""{
+      return
+    }
+    var expanded strings.Builder
+    cells := 0
+    for _, r := range text{
+      if r == '\t' {
+        //tab stops every 8 cells
+        pad := 8 - (col+cells)%8
+        expanded.WriteString(strings.Repeat(" ", pad))
+        cells += pad
+        continue
+      }
+      if r == '\r' {
+        continue
+      }
+      expanded.WriteRune(r)
+      cells += runeWidth(r)
+    }
+    last := len(lines) - 1
+    lines[last] = append(lines[last], svgRun{col: col, cells: cells, text: expanded.String(), state: state})
+    col += cells
+    if col > maxCols {
+      maxCols = col
+    }
+  }
+
+  for _, span := range spans{
+    if span.Tag != ""{
+      state.apply(span.Tag)
+      continue
+    }
+    for i, line := range strings.Split(span.Text, "\n"){
+      if i > 0 {
+        lines = append(lines, nil)
+        col = 0
+      }
+      addRun(line)
+    }
+  }
+
+  //drop the empty line after a trailing newline
+  if len(lines) > 1 && len(lines[len(lines)-1]) == 0{
+    lines = lines[:len(lines)-1]
+  }
+  return lines, maxCols
+}
+
+
+//WriteSVG draws spans as an svg "terminal screenshot" and writes it to w
+func WriteSVG(w io.Writer, spans []Span, opts SVGOptions) error {
+  theme := DefaultSVGTheme
+  if opts.Theme != nil {
+    theme = *opts.Theme
+  }
+  if opts.FontFamily == ""{
+    opts.FontFamily = "ui-monospace, Menlo, Consolas, 'DejaVu Sans Mono', monospace"
+  }
+  if opts.FontSize <= 0{
+    opts.FontSize = 14
+  }
+  if opts.LineHeight <= 0{
+    opts.LineHeight = 1.4
+  }
+  if opts.Padding <= 0{
+    opts.Padding = 16
+  }
+
+  lines, cols := svgLines(spans)
+  cellWidth := opts.FontSize * 0.6
+  lineHeight := opts.FontSize * opts.LineHeight
+  top := opts.Padding
+  if opts.Window {
+    top += 28
+  }
+  width := float64(cols)*cellWidth + opts.Padding*2
+  height := float64(len(lines))*lineHeight + top + opts.Padding
+
+  var out strings.Builder
+  fmt.Fprintf(&out, `<svg xmlns="http://www.w3.org/2000/svg" width="%s" height="%s" viewBox="0 0 %s %s">`+"\n",
+    svgNum(width), svgNum(height), svgNum(width), svgNum(height))
+  fmt.Fprintf(&out, `<rect width="100%%" height="100%%" rx="%s" fill="%s"/>`+"\n", svgNum(boolNum(opts.Window, 8)), theme.Background)
+
+  if opts.Window {
+    for i, fill := range []string{"#ff5f56", "#ffbd2e", "#27c93f"}{
+      fmt.Fprintf(&out, `<circle cx="%s" cy="16" r="6" fill="%s"/>`+"\n", svgNum(opts.Padding+float64(i)*20), fill)
+    }
+    if opts.Title != ""{
+      fmt.Fprintf(&out, `<text x="%s" y="20" text-anchor="middle" fill="%s" font-family="%s" font-size="%s">%s</text>`+"\n",
+        svgNum(width/2), theme.Foreground, html.EscapeString(opts.FontFamily), svgNum(opts.FontSize*0.9), html.EscapeString(opts.Title))
+    }
+  }
+
+  fmt.Fprintf(&out, `<g font-family="%s" font-size="%s" xml:space="preserve">`+"\n", html.EscapeString(opts.FontFamily), svgNum(opts.FontSize))
+  for row, line := range lines{
+    y := top + float64(row)*lineHeight
+    baseline := y + lineHeight*0.75
+    for _, run := range line{
+      x := opts.Padding + float64(run.col)*cellWidth
+      runWidth := float64(run.cells) * cellWidth
+      fg, bg := run.state.colors()
+
+      fill := theme.Foreground
+      if fg.set {
+        fill = svgColor(fg, theme)
+      } else if run.state.reverse {
+        fill = theme.Background
+      }
+      if bg.set || run.state.reverse {
+        back := theme.Foreground
+        if bg.set {
+          back = svgColor(bg, theme)
+        }
+        fmt.Fprintf(&out, `<rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>`+"\n",
+          svgNum(x), svgNum(y), svgNum(runWidth), svgNum(lineHeight), back)
+      }
+      if run.state.hidden || strings.TrimSpace(run.text) == ""{
+        continue
+      }
+
+      attrs := fmt.Sprintf(`x="%s" y="%s" fill="%s" textLength="%s" lengthAdjust="spacingAndGlyphs"`,
+        svgNum(x), svgNum(baseline), fill, svgNum(runWidth))
+      if run.state.bold {
+        attrs += ` font-weight="bold"`
+      }
+      if run.state.italic {
+        attrs += ` font-style="italic"`
+      }
+      if run.state.dim {
+        attrs += ` opacity="0.5"`
+      }
+      var decoration []string
+      if run.state.underline > 0{
+        decoration = append(decoration, "underline")
+      }
+      if run.state.strike {
+        decoration = append(decoration, "line-through")
+      }
+      if len(decoration) > 0{
+        attrs += ` text-decoration="` + strings.Join(decoration, " ") + `"`
+      }
+      fmt.Fprintf(&out, "<text %s>%s</text>\n", attrs, html.EscapeString(run.text))
+    }
+  }
+  out.WriteString("</g>\n</svg>\n")
+
+  _, err := io.WriteString(w, out.String())
+  return err
+}
+
+func svgColor(c stateColor, theme SVGTheme) string {
+  if c.name != "" && theme.Palette[c.index] != ""{
+    return theme.Palette[c.index]
+  }
+  return c.hex()
+}
+
+//svgNum writes a number without trailing zeros
+func svgNum(n float64) string {
+  s := fmt.Sprintf("%.2f", n)
+  s = strings.TrimRight(s, "0")
+  return strings.TrimSuffix(s, ".")
+}
+
+func boolNum(b bool, n float64) float64 {
+  if b {
+    return n
+  }
+  return 0
+}
@@ -0,0 +1,143 @@
+package color
+
+import "math"
+
+//===========================================
+//  PALETTES AND THEMES
+//===========================================
+
+//ToneSteps are the keys of the scale Tones returns, lightest first
+var ToneSteps = []int{50, 100, 200, 300, 400, 500, 600, 700, 800, 900}
+
+//rotate turns the OKLCH hue by degrees, keeping lightness and chroma
+func (c Color) rotate(degrees float64) Color {
+  l, chroma, h := c.OKLCH()
+  return OKLCH(l, chroma, math.Mod(h+degrees+360, 360))
+}
+
+//Complementary returns the color on the other side of the OKLCH hue circle
+func (c Color) Complementary() Color {
+  return c.rotate(180)
+}
+
+//Analogous returns c between its neighbours 30 degrees either side
+func (c Color) Analogous() []Color {
+  return []Color{c.rotate(-30), c, c.rotate(30)}
+}
+
+//Triadic returns c and the two colors a third of the hue circle away
+func (c Color) Triadic() []Color {
+  return []Color{c, c.rotate(120), c.rotate(240)}
+}
+
+//Tones returns shades of c from 50 (near white) to 900 (near black), keyed by ToneSteps.
+//lightness steps are even in OKLCH, c itself takes the step nearest its own
+//lightness (500 for a mid tone, 50 for white), and hue and chroma are kept as far
+//as the gamut allows
+func (c Color) Tones() map[int]Color {
+  const lightest, darkest = 0.97, 0.25
+  l, chroma, h := c.OKLCH()
+  last := len(ToneSteps) - 1
+
+  tones := make(map[int]Color, len(ToneSteps))
+  for i, step := range ToneSteps{
+    tones[step] = OKLCH(lightest+(darkest-lightest)*float64(i)/float64(last), chroma, h)
+  }
+  //within half a step of its lightness, so the scale stays in order
+  at := int(math.Round((lightest - l) / (lightest - darkest) * float64(last)))
+  tones[ToneSteps[max(0, min(last, at))]] = c
+  return tones
+}
+
+
+//Theme gives the colors of a palette semantic roles, so templates can
+//ask for "error" instead of a hue
+type Theme struct {
+  Primary   Color
+  Secondary Color
+  Accent    Color
+
+  Success Color
+  Warning Color
+  Error   Color
+  Info    Color
+
+  Background Color
+  Surface    Color
+  Text       Color
+  Muted      Color
+}
+
+//hues of the status roles, matched to the primary's lightness and chroma
+var statusHues = map[string]float64{
+  "success": 145,
+  "warning": 75,
+  "error":   27,
+  "info":    235,
+}
+
+//NewTheme fills a light theme from a palette like the ones Analogous or Triadic
+//return: the first color is the primary, the next two the secondary and accent.
+//missing ones are made from the primary. neutrals are tinted with its hue
+func NewTheme(palette []Color) Theme {
+  return newTheme(palette, false)
+}
+
+//NewDarkTheme is NewTheme with a dark background and light text
+func NewDarkTheme(palette []Color) Theme {
+  return newTheme(palette, true)
+}
+
+func newTheme(palette []Color, dark bool) Theme {
+  var primary Color
+  if len(palette) > 0 {
+    primary = palette[0]
+  }
+  theme := Theme{
+    Primary:   primary,
+    Secondary: primary.Complementary(),
+    Accent:    primary.rotate(120),
+  }
+  if len(palette) > 1 {
+    theme.Secondary = palette[1]
+  }
+  if len(palette) > 2 {
+    theme.Accent = palette[2]
+  }
+
+  _, chroma, hue := primary.OKLCH()
+  statusL := 0.6
+  if dark {
+    statusL = 0.75
+  }
+  status := func(role string) Color {
+    return OKLCH(statusL, math.Max(chroma, 0.12), statusHues[role])
+  }
+  theme.Success, theme.Warning = status("success"), status("warning")
+  theme.Error, theme.Info = status("error"), status("info")
+
+  neutral := OKLCH(0.6, math.Min(chroma, 0.02), hue).Tones()
+  if dark {
+    theme.Background, theme.Surface, theme.Text, theme.Muted = neutral[900], neutral[800], neutral[50], neutral[300]
+  } else {
+    theme.Background, theme.Surface, theme.Text, theme.Muted = neutral[50], neutral[100], neutral[900], neutral[600]
+  }
+  return theme
+}
+
+//Roles returns the theme by role name ("primary", "error", "background"...)
+func (theme Theme) Roles() map[string]Color {
+  return map[string]Color{
+    "primary":    theme.Primary,
+    "secondary":  theme.Secondary,
+    "accent":     theme.Accent,
+    "success":    theme.Success,
+    "warning":    theme.Warning,
+    "error":      theme.Error,
+    "info":       theme.Info,
+    "background": theme.Background,
+    "surface":    theme.Surface,
+    "text":       theme.Text,
+    "muted":      theme.Muted,
+  }
+}
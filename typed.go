@@ -0,0 +1,125 @@
+package color
+
+import (
+  "fmt"
+  "slices"
+)
+
+//===========================================
+//  TYPED TEMPLATES
+//===========================================
+
+/* T1, T2 and T3 wrap a template whose placeholders are exactly [0], [0]-[1] or
+   [0]-[2], so Apply takes that many arguments of the given types:
+
+     line := color.MustParse2[string, int]("[0 bold]: [1 scale=90:green,0:red]")
+     line.Apply("Alice", 95)
+
+   a placeholder may appear more than once, but no slot may be missing or extra */
+
+//Slots returns the placeholder indices the template uses, sorted and without repeats
+func (temp CompiledTemplate) Slots() []int {
+  var slots []int
+  var walk func(parts []TempPart)
+  walk = func(parts []TempPart) {
+    for _, part := range parts{
+      switch {
+      case part.block != nil:
+        walk(part.block.inner.Parts)
+      case part.Index >= 0:
+        slots = append(slots, part.Index)
+      }
+    }
+  }
+  walk(temp.Parts)
+  slices.Sort(slots)
+  return slices.Compact(slots)
+}
+
+//checkSlots reports if the template doesn't use exactly slots 0 to count-1
+func checkSlots(temp CompiledTemplate, count int) error {
+  slots := temp.Slots()
+  want := make([]int, count)
+  for i := range want{
+    want[i] = i
+  }
+  if !slices.Equal(slots, want) {
+    return fmt.Errorf("color: template uses placeholders %v, want exactly %v", slots, want)
+  }
+  return nil
+}
+
+//T1 is a template with one placeholder, [0]
+type T1[A any] struct {
+  temp CompiledTemplate
+}
+
+//T2 is a template with two placeholders, [0] and [1]
+type T2[A, B any] struct {
+  temp CompiledTemplate
+}
+
+//T3 is a template with three placeholders, [0] to [2]
+type T3[A, B, C any] struct {
+  temp CompiledTemplate
+}
+
+func (t T1[A]) Apply(a A) string                 { return t.temp.Apply(a) }
+func (t T2[A, B]) Apply(a A, b B) string         { return t.temp.Apply(a, b) }
+func (t T3[A, B, C]) Apply(a A, b B, c C) string { return t.temp.Apply(a, b, c) }
+
+func (t T1[A]) Render(r Renderer, a A) string                 { return t.temp.Render(r, a) }
+func (t T2[A, B]) Render(r Renderer, a A, b B) string         { return t.temp.Render(r, a, b) }
+func (t T3[A, B, C]) Render(r Renderer, a A, b B, c C) string { return t.temp.Render(r, a, b, c) }
+
+//Template returns the untyped template
+func (t T1[A]) Template() CompiledTemplate       { return t.temp }
+func (t T2[A, B]) Template() CompiledTemplate    { return t.temp }
+func (t T3[A, B, C]) Template() CompiledTemplate { return t.temp }
+
+//Typed1 checks a compiled template, from any toggle or TemplateSet, and wraps it
+func Typed1[A any](temp CompiledTemplate) (T1[A], error) {
+  return T1[A]{temp}, checkSlots(temp, 1)
+}
+
+func Typed2[A, B any](temp CompiledTemplate) (T2[A, B], error) {
+  return T2[A, B]{temp}, checkSlots(temp, 2)
+}
+
+func Typed3[A, B, C any](temp CompiledTemplate) (T3[A, B, C], error) {
+  return T3[A, B, C]{temp}, checkSlots(temp, 3)
+}
+
+//Parse1 is Parse for a template with one placeholder
+func Parse1[A any](input string) (T1[A], error) {
+  return Typed1[A](Parse(input))
+}
+
+func Parse2[A, B any](input string) (T2[A, B], error) {
+  return Typed2[A, B](Parse(input))
+}
+
+func Parse3[A, B, C any](input string) (T3[A, B, C], error) {
+  return Typed3[A, B, C](Parse(input))
+}
+
+//MustParse1 is Parse1 that panics on a template with the wrong placeholders,
+//for package level templates
+func MustParse1[A any](input string) T1[A] {
+  return must(Parse1[A](input))
+}
+
+func MustParse2[A, B any](input string) T2[A, B] {
+  return must(Parse2[A, B](input))
+}
+
+func MustParse3[A, B, C any](input string) T3[A, B, C] {
+  return must(Parse3[A, B, C](input))
+}
+
+func must[T any](t T, err error) T {
+  if err != nil {
+    panic(err)
+  }
+  return t
+}
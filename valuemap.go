@@ -0,0 +1,150 @@
+package color
+
+import (
+  "fmt"
+  "maps"
+  "strings"
+)
+
+//===========================================
+//  VALUE MAPS
+//===========================================
+
+/* a map styles a placeholder by its value, picked when the template is applied:
+
+     [1 map=status]                                          a map of the TemplateSet
+     [1 map=(Online: fg=green bold, Offline: fg=red, *: fg=yellow)]   a map in the template
+
+   "*" is the default for values the map doesn't list */
+
+//ValueMap maps placeholder values to style words like "fg=green bold"
+type ValueMap struct {
+  Values map[string]string
+  //the style of values not in Values, none when empty
+  Default string
+}
+
+//TemplateSet parses templates that share value maps
+type TemplateSet struct {
+  Toggle *ColorToggle
+  Maps   map[string]ValueMap
+}
+
+//NewTemplateSet makes an empty set for toggle, a detected one when nil
+func NewTemplateSet(toggle *ColorToggle) *TemplateSet {
+  if toggle == nil {
+    toggle = NewColorToggle()
+  }
+  return &TemplateSet{Toggle: toggle, Maps: map[string]ValueMap{}}
+}
+
+//Map adds or replaces a map and returns the set, so maps can be chained
+func (set *TemplateSet) Map(name string, values map[string]string, fallback string) *TemplateSet {
+  if set.Maps == nil {
+    set.Maps = map[string]ValueMap{}
+  }
+  set.Maps[name] = ValueMap{Values: values, Default: fallback}
+  return set
+}
+
+//Parse compiles a template that can use the maps of the set.
+//a map is read when the template is parsed, changing it later has no effect on it
+func (set *TemplateSet) Parse(input string) CompiledTemplate {
+  toggle := set.Toggle
+  if toggle == nil {
+    toggle = NewColorToggle()
+  }
+  withMaps := *toggle
+  withMaps.maps = set.Maps
+  return withMaps.Parse(input)
+}
+
+
+//parseValueMap reads the value of a map= word, a name or an inline (value: styles, ...).
+//every style is resolved like a tag at the placeholder
+func (toggle *ColorToggle) parseValueMap(arg string, ctx colorContext) (func(value string) []string, error) {
+  var valueMap ValueMap
+  if inline, isInline := strings.CutPrefix(arg, "("); isInline {
+    var err error
+    if valueMap, err = inlineValueMap(strings.TrimSuffix(inline, ")")); err != nil {
+      return nil, err
+    }
+  } else {
+    var exists bool
+    if valueMap, exists = toggle.maps[arg]; !exists {
+      return nil, fmt.Errorf("color: no value map named %q", arg)
+    }
+  }
+
+  resolve := func(style string) ([]string, error) {
+    scratch := ctx
+    scratch.active = maps.Clone(ctx.active)
+    var tags []string
+    for _, word := range tagWords(style){
+      if !IsSupportedColor(word) {
+        return nil, fmt.Errorf("color: %q in value map is not a style or a color", word)
+      }
+      tags = append(tags, toggle.tagPart(word, &scratch).Tag)
+    }
+    return tags, nil
+  }
+
+  styles := make(map[string][]string, len(valueMap.Values))
+  for value, style := range valueMap.Values{
+    tags, err := resolve(style)
+    if err != nil {
+      return nil, err
+    }
+    styles[value] = tags
+  }
+  fallback, err := resolve(valueMap.Default)
+  if err != nil {
+    return nil, err
+  }
+
+  return func(value string) []string {
+    if tags, exists := styles[value]; exists {
+      return tags
+    }
+    return fallback
+  }, nil
+}
+
+//inlineValueMap reads "Online: fg=green bold, Offline: fg=red, *: fg=yellow"
+func inlineValueMap(content string) (ValueMap, error) {
+  valueMap := ValueMap{Values: map[string]string{}}
+  for _, entry := range splitOutsideParens(content, ','){
+    value, style, found := strings.Cut(entry, ":")
+    value = strings.TrimSpace(value)
+    if !found || value == "" {
+      return ValueMap{}, fmt.Errorf("color: value map entry %q is not value: styles", entry)
+    }
+    if value == "*" {
+      valueMap.Default = style
+    } else {
+      valueMap.Values[value] = style
+    }
+  }
+  return valueMap, nil
+}
+
+//splitOutsideParens splits s at sep, except inside (), so rgb(1,2,3) stays whole
+func splitOutsideParens(s string, sep rune) []string {
+  var (
+    fields []string
+    depth  = 0
+    start  = 0
+  )
+  for i, r := range s{
+    switch {
+    case r == '(':
+      depth++
+    case r == ')' && depth > 0:
+      depth--
+    case r == sep && depth == 0:
+      fields = append(fields, s[start:i])
+      start = i + len(string(sep))
+    }
+  }
+  return append(fields, s[start:])
+}
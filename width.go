@@ -0,0 +1,159 @@
+package color
+
+import "unicode"
+
+//===========================================
+//  CELL WIDTHS
+//===========================================
+
+//ranges of runes that take two terminal cells (east asian wide/fullwidth and emoji)
+var wideRanges = [][2]rune{
+  {0x1100, 0x115F},   //hangul jamo
+  {0x231A, 0x231B},   //watch, hourglass
+  {0x2329, 0x232A},
+  {0x23E9, 0x23EC},
+  {0x23F0, 0x23F0},
+  {0x23F3, 0x23F3},
+  {0x25FD, 0x25FE},
+  {0x2614, 0x2615},
+  {0x2648, 0x2653},
+  {0x267F, 0x267F},
+  {0x2693, 0x2693},
+  {0x26A1, 0x26A1},
+  {0x26AA, 0x26AB},
+  {0x26BD, 0x26BE},
+  {0x26C4, 0x26C5},
+  {0x26CE, 0x26CE},
+  {0x26D4, 0x26D4},
+  {0x26EA, 0x26EA},
+  {0x26F2, 0x26F3},
+  {0x26F5, 0x26F5},
+  {0x26FA, 0x26FA},
+  {0x26FD, 0x26FD},
+  {0x2705, 0x2705},
+  {0x270A, 0x270B},
+  {0x2728, 0x2728},
+  {0x274C, 0x274C},
+  {0x274E, 0x274E},
+  {0x2753, 0x2755},
+  {0x2757, 0x2757},
+  {0x2795, 0x2797},
+  {0x27B0, 0x27B0},
+  {0x27BF, 0x27BF},
+  {0x2B1B, 0x2B1C},
+  {0x2B50, 0x2B50},
+  {0x2B55, 0x2B55},
+  {0x2E80, 0x303E},   //cjk radicals, punctuation
+  {0x3041, 0x33FF},   //kana, cjk compatibility
+  {0x3400, 0x4DBF},   //cjk extension a
+  {0x4E00, 0x9FFF},   //cjk unified ideographs
+  {0xA000, 0xA4CF},   //yi
+  {0xA960, 0xA97F},
+  {0xAC00, 0xD7A3},   //hangul syllables
+  {0xF900, 0xFAFF},   //cjk compatibility ideographs
+  {0xFE10, 0xFE19},
+  {0xFE30, 0xFE6F},
+  {0xFF00, 0xFF60},   //fullwidth forms
+  {0xFFE0, 0xFFE6},
+  {0x16FE0, 0x16FE4},
+  {0x17000, 0x18CFF}, //tangut
+  {0x1B000, 0x1B2FF}, //kana supplement
+  {0x1F004, 0x1F004},
+  {0x1F0CF, 0x1F0CF},
+  {0x1F18E, 0x1F18E},
+  {0x1F191, 0x1F19A},
+  {0x1F200, 0x1F251},
+  {0x1F300, 0x1F64F}, //pictographs, emoticons
+  {0x1F680, 0x1F6FF}, //transport
+  {0x1F7E0, 0x1F7EB},
+  {0x1F90C, 0x1F9FF}, //supplemental symbols
+  {0x1FA70, 0x1FAFF},
+  {0x20000, 0x2FFFD}, //cjk extension b and up
+  {0x30000, 0x3FFFD},
+}
+
+//runeWidth returns how many terminal cells a rune takes: 0, 1 or 2
+func runeWidth(r rune) int {
+  if r == 0 || r == 0x200B || r == 0x200C || r == 0x200D || r == 0xFEFF {
+    return 0
+  }
+  if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.IsControl(r) {
+    return 0
+  }
+  //variation selectors
+  if (r >= 0xFE00 && r <= 0xFE0F) || (r >= 0xE0100 && r <= 0xE01EF) {
+    return 0
+  }
+  if r < 0x1100 {
+    return 1
+  }
+  for _, wide := range wideRanges{
+    if r < wide[0] {
+      break
+    }
+    if r <= wide[1] {
+      return 2
+    }
+  }
+  return 1
+}
+
+//textWidth returns how many terminal cells a string takes
+func textWidth(s string) int {
+  width := 0
+  for _, r := range s{
+    width += runeWidth(r)
+  }
+  return width
+}
+
+//joinsPrevious reports if r belongs to the grapheme before it
+func joinsPrevious(r rune) bool {
+  return unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Me, r) || unicode.Is(unicode.Mc, r) ||
+    (r >= 0xFE00 && r <= 0xFE0F) || (r >= 0xE0100 && r <= 0xE01EF) || //variation selectors
+    (r >= 0x1F3FB && r <= 0x1F3FF) || //skin tone modifiers
+    (r >= 0xE0020 && r <= 0xE007F) || //emoji tag sequences
+    r == 0x200D
+}
+
+func isRegionalIndicator(r rune) bool {
+  return r >= 0x1F1E6 && r <= 0x1F1FF
+}
+
+//graphemes splits text into what a reader sees as single characters: combining marks,
+//zero width joiner sequences and flag pairs stay with the character they belong to
+func graphemes(s string) []string {
+  var (
+    result  []string
+    start   = 0
+    prev    rune
+    flagRun = 0
+  )
+  for i, r := range s{
+    if i == 0 {
+      prev = r
+      if isRegionalIndicator(r) {
+        flagRun = 1
+      }
+      continue
+    }
+    join := joinsPrevious(r) || prev == 0x200D
+    if isRegionalIndicator(r) {
+      if flagRun%2 == 1 {
+        join = true
+      }
+      flagRun++
+    } else {
+      flagRun = 0
+    }
+    if !join {
+      result = append(result, s[start:i])
+      start = i
+    }
+    prev = r
+  }
+  if start < len(s) {
+    result = append(result, s[start:])
+  }
+  return result
+}